@@ -0,0 +1,221 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-logger/log"
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+// RFC 6762 - Multicast DNS.
+const (
+	// AnnounceCount is the number of unsolicited responses to announce the newly registered records.
+	// 8.3. Announcing
+	AnnounceCount = 2
+	// AnnounceInterval is the interval between the announcements.
+	// 8.3. Announcing
+	AnnounceInterval = time.Second
+)
+
+// announcer represents an announcing process for the records of a registration.
+type announcer struct {
+	service  Service
+	cancelCh chan struct{}
+}
+
+// announcerSet represents a set of the running announcers.
+type announcerSet struct {
+	sync.Mutex
+	wg         sync.WaitGroup
+	announcers []*announcer
+}
+
+// newAnnouncer returns a new announcer for the specified service. The nil service represents the host records.
+func newAnnouncer(service Service) *announcer {
+	return &announcer{
+		service:  service,
+		cancelCh: make(chan struct{}),
+	}
+}
+
+// newAnnouncerSet returns a new announcer set.
+func newAnnouncerSet() *announcerSet {
+	return &announcerSet{
+		Mutex:      sync.Mutex{},
+		wg:         sync.WaitGroup{},
+		announcers: []*announcer{},
+	}
+}
+
+// addAnnouncer adds the specified announcer to be canceled or waited.
+func (set *announcerSet) addAnnouncer(a *announcer) {
+	set.Lock()
+	defer set.Unlock()
+	set.announcers = append(set.announcers, a)
+	set.wg.Add(1)
+}
+
+// removeAnnouncer removes the specified finished announcer.
+func (set *announcerSet) removeAnnouncer(a *announcer) {
+	set.Lock()
+	defer set.Unlock()
+	set.announcers = slices.DeleteFunc(set.announcers, func(other *announcer) bool {
+		return other == a
+	})
+	set.wg.Done()
+}
+
+// cancelAnnouncers cancels the running announcers for the specified service. The nil service represents the host records.
+func (set *announcerSet) cancelAnnouncers(service Service) {
+	set.Lock()
+	defer set.Unlock()
+	for _, a := range set.announcers {
+		if a.isService(service) {
+			a.cancel()
+		}
+	}
+}
+
+// stopAnnouncers cancels all the running announcers, and waits until they finish.
+func (set *announcerSet) stopAnnouncers() {
+	set.Lock()
+	for _, a := range set.announcers {
+		a.cancel()
+	}
+	set.Unlock()
+	set.wg.Wait()
+}
+
+// isService returns true if the announcer announces the records of the specified service, otherwise false.
+func (a *announcer) isService(service Service) bool {
+	if a.service == nil || service == nil {
+		return a.service == service
+	}
+	return a.service.Equal(service)
+}
+
+// cancel cancels the announcer. The announcer set must be locked by the caller.
+func (a *announcer) cancel() {
+	select {
+	case <-a.cancelCh:
+	default:
+		close(a.cancelCh)
+	}
+}
+
+// isCanceled returns true if the announcer is canceled, otherwise false.
+func (a *announcer) isCanceled() bool {
+	select {
+	case <-a.cancelCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// announceMessage returns an unsolicited response message containing all the specified records.
+// RFC 6762: 8.3. Announcing
+func announceMessage(records ResourceRecordSet) Message {
+	return dns.NewResponseMessage(dns.WithMessageAnswers(records...))
+}
+
+// announceService announces all the records of the specified service in the background while the service is registered.
+// The announcements are canceled when the service is unregistered or replaced by UpdateService,
+// so that the outdated records are not announced again.
+// RFC 6762: 8.3. Announcing
+// RFC 6762: 8.4. Updating.
+func (server *Server) announceService(service Service) {
+	records, err := server.serviceRecords(service)
+	if err != nil {
+		return
+	}
+	isAnnounceable := func() bool {
+		server.Lock()
+		defer server.Unlock()
+		return server.IsRunning() && slices.Contains(server.Services(), service)
+	}
+	server.announce(service, func(net.IP) ResourceRecordSet { return records }, isAnnounceable)
+}
+
+// announce multicasts the records returned by the specified function for the local address of each interface in the background
+// while the records are announceable and the announcer is not canceled. The nil service represents the host records.
+// RFC 6762: 8.3. Announcing
+func (server *Server) announce(service Service, interfaceRecords func(ifaddr net.IP) ResourceRecordSet, isAnnounceable func() bool) {
+	a := newAnnouncer(service)
+	server.addAnnouncer(a)
+	go func() {
+		defer server.removeAnnouncer(a)
+		for n := range AnnounceCount {
+			if 0 < n {
+				select {
+				case <-a.cancelCh:
+					return
+				case <-time.After(AnnounceInterval):
+				}
+			}
+			if a.isCanceled() || !isAnnounceable() {
+				return
+			}
+			records, err := server.multicastInterfaceRecords(interfaceRecords)
+			if err != nil {
+				if server.IsRunning() {
+					log.Error(err)
+				}
+				return
+			}
+			server.multicastRecords("", records)
+		}
+	}()
+}
+
+// multicastInterfaceRecords multicasts the records returned by the specified function for the local address of each interface,
+// and returns all the sent records.
+// RFC 6762: 14. Considerations for Multiple Interfaces
+func (server *Server) multicastInterfaceRecords(interfaceRecords func(ifaddr net.IP) ResourceRecordSet) (ResourceRecordSet, error) {
+	sentRecords := ResourceRecordSet{}
+	err := server.AnnounceInterfaceMessage(func(ifaddr string) dns.Message {
+		addr, _, _ := strings.Cut(ifaddr, "%")
+		records := interfaceRecords(net.ParseIP(addr))
+		if len(records) == 0 {
+			return nil
+		}
+		for _, record := range records {
+			if !slices.ContainsFunc(sentRecords, record.Equal) {
+				sentRecords = append(sentRecords, record)
+			}
+		}
+		return announceMessage(records)
+	})
+	return sentRecords, err
+}
+
+// sendGoodbye multicasts the specified records with TTL zero to notify that the records are no longer valid.
+// The TTLs of the specified records are overwritten.
+// RFC 6762: 10.1. Goodbye Packets
+func (server *Server) sendGoodbye(records ResourceRecordSet) error {
+	if !server.IsRunning() || len(records) == 0 {
+		return nil
+	}
+	for _, record := range records {
+		record.SetTTL(0)
+	}
+	return server.AnnounceMessage(announceMessage(records))
+}
@@ -0,0 +1,194 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cybergarage/go-logger/log"
+)
+
+// RFC 6762: 5.2. Continuous Multicast DNS Querying
+// The interval between the first two queries MUST be at least one second,
+// the intervals between successive queries MUST increase by at least a factor of two.
+// When the interval between queries reaches or exceeds 60 minutes, a querier MAY cap the interval to a maximum of 60 minutes.
+const (
+	// BrowseInitialInterval is the interval between the first two queries of a browse.
+	BrowseInitialInterval = time.Duration(1) * time.Second
+	// BrowseMaxInterval is the maximum interval between the successive queries of a browse.
+	BrowseMaxInterval = time.Duration(60) * time.Minute
+)
+
+const (
+	// BrowseEventBufferSize is the number of the service events buffered in the channel returned by Browse.
+	BrowseEventBufferSize = 64
+)
+
+// browseState represents the services found by a browse.
+type browseState struct {
+	services   map[string]Service
+	signatures map[string]string
+}
+
+// newBrowseState returns a new browse state.
+func newBrowseState() *browseState {
+	return &browseState{
+		services:   map[string]Service{},
+		signatures: map[string]string{},
+	}
+}
+
+// serviceSignature returns the string which represents the SRV and TXT data of the specified service.
+func serviceSignature(service Service) string {
+	attrs := []string{}
+	for _, attr := range service.ResourceAttributes() {
+		attrs = append(attrs, attr.String())
+	}
+	slices.Sort(attrs)
+	return fmt.Sprintf("%s:%d %s", strings.ToLower(service.Host()), service.Port(), strings.Join(attrs, " "))
+}
+
+// update updates the state with the specified services keyed by the instance names,
+// and returns the events of the added, updated and removed services.
+func (state *browseState) update(services map[string]Service) []ServiceEvent {
+	events := []ServiceEvent{}
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		service := services[name]
+		signature := serviceSignature(service)
+		lastSignature, ok := state.signatures[name]
+		switch {
+		case !ok:
+			events = append(events, newServiceEvent(ServiceAdded, service))
+		case signature != lastSignature:
+			events = append(events, newServiceEvent(ServiceUpdated, service))
+		}
+		state.services[name] = service
+		state.signatures[name] = signature
+	}
+	names = []string{}
+	for name := range state.services {
+		if _, ok := services[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		events = append(events, newServiceEvent(ServiceRemoved, state.services[name]))
+		delete(state.services, name)
+		delete(state.signatures, name)
+	}
+	return events
+}
+
+// Browse sends question messages to the multicast address continuously until the context is done,
+// and notifies the events of the services which answer the query into the returned channel.
+// The events are notified when the answers are received into the cache, and the services are removed
+// one second after the goodbye packets are received or when their records expire.
+// The channel buffers BrowseEventBufferSize events. If the caller does not receive the events and the buffer is full,
+// the browse stalls, and no more queries are sent until the caller receives the events or the context is done.
+// RFC 6762: 5.2. Continuous Multicast DNS Querying
+func (client *clientImpl) Browse(ctx context.Context, q Query) (<-chan ServiceEvent, error) {
+	handler, _ := q.MessageHandler()
+	collector := newQueryCollector(q.Questions(), handler)
+	client.addCollector(collector)
+
+	scheduler := newQueryScheduler(q, client.cache)
+	queryMsgs := client.queryMessages(scheduler)
+	if err := client.sendQueryMessages(queryMsgs); err != nil {
+		client.removeCollector(collector)
+		return nil, err
+	}
+
+	questions := queryMsgs[0].Questions()
+	for _, question := range questions {
+		client.addInterest(question)
+	}
+
+	events := make(chan ServiceEvent, BrowseEventBufferSize)
+	go func() {
+		defer func() {
+			for _, question := range questions {
+				client.removeInterest(question)
+			}
+			client.removeCollector(collector)
+			close(events)
+		}()
+
+		state := newBrowseState()
+		lastSent := time.Now()
+		queryTimer := time.NewTimer(scheduler.nextInterval())
+		defer queryTimer.Stop()
+		// The expiration timer notifies the removed services whose records expire without any updates.
+		expirationTimer := time.NewTimer(0)
+		defer expirationTimer.Stop()
+		updates := client.cache.updates()
+
+		notifyEvents := func() bool {
+			services := map[string]Service{}
+			for _, name := range client.answeredNames(questions) {
+				if service, ok := client.cache.LookupService(name); ok {
+					services[strings.ToLower(name)] = service
+				}
+			}
+			for _, event := range state.update(services) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			if next, ok := client.cache.nextExpiration(); ok {
+				expirationTimer.Reset(max(time.Until(next), 0))
+			}
+			return true
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-queryTimer.C:
+				// RFC 6762: 7.3. Duplicate Question Suppression
+				if !client.isQuestionSuppressed(questions, lastSent) {
+					if err := client.sendQueryMessages(client.queryMessages(scheduler)); err != nil {
+						log.Error(err)
+					}
+				}
+				lastSent = time.Now()
+				queryTimer.Reset(scheduler.nextInterval())
+			case <-updates:
+				updates = client.cache.updates()
+				if !notifyEvents() {
+					return
+				}
+			case <-expirationTimer.C:
+				if !notifyEvents() {
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
@@ -0,0 +1,34 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+// Cache represents a record cache which holds the received resource records until their TTLs expire.
+// RFC 6762: 10. Resource Record TTL Values and Cache Coherency.
+type Cache interface {
+	// Records returns all unexpired records. The TTL of each record is the remaining TTL in seconds.
+	Records() ResourceRecordSet
+	// LookupRecords returns the unexpired records which have the specified name, type and class.
+	LookupRecords(name string, typ dns.Type, cls dns.Class) ResourceRecordSet
+	// LookupService returns the service of the specified instance name composed of the unexpired records.
+	LookupService(name string) (Service, bool)
+	// Services returns all services composed of the unexpired records.
+	Services() []Service
+	// Flush removes all records.
+	Flush()
+}
@@ -0,0 +1,491 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-logger/log"
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+// RFC 6762 - Multicast DNS.
+const (
+	// GoodbyeTTL is the time to keep the records received with TTL zero or flushed by the cache-flush bit.
+	// 10.1. Goodbye Packets
+	// 10.2. Announcements to Flush Outdated Cache Entries
+	GoodbyeTTL = time.Second
+	// CacheRefreshJitter is the maximum random variation added to the refresh points of the record TTL.
+	// 5.2. Continuous Multicast DNS Querying
+	CacheRefreshJitter = 0.02
+)
+
+// CacheRefreshRatios are the points of the record TTL when the querier sends the queries to refresh the record.
+// RFC 6762: 5.2. Continuous Multicast DNS Querying
+// The querier should plan to issue a query at 80% of the record lifetime, and then if no answer is received,
+// at 85%, 90%, and 95%. If an answer is received, then the remaining TTL of the record is reset.
+var CacheRefreshRatios = []float64{0.80, 0.85, 0.90, 0.95}
+
+// cacheEntry represents a cached record.
+type cacheEntry struct {
+	record    ResourceRecord
+	ifkey     string
+	received  time.Time
+	multicast time.Time
+	expires   time.Time
+	ttl       time.Duration
+	jitter    float64
+	refreshes int
+}
+
+// cacheImpl represents a record cache keyed by the record name, type and class.
+type cacheImpl struct {
+	sync.Mutex
+	entries map[string][]*cacheEntry
+	updated chan struct{}
+	now     func() time.Time
+}
+
+// newCache returns a new record cache.
+func newCache() *cacheImpl {
+	return &cacheImpl{
+		Mutex:   sync.Mutex{},
+		entries: map[string][]*cacheEntry{},
+		updated: make(chan struct{}),
+		now:     time.Now,
+	}
+}
+
+// cacheKey returns the cache key of the specified name, type and class.
+func cacheKey(name string, typ dns.Type, cls dns.Class) string {
+	return strings.Join([]string{strings.ToLower(name), strconv.Itoa(int(typ)), strconv.Itoa(int(cls))}, "/")
+}
+
+// newCacheEntry returns a new cache entry of the specified record received now.
+func newCacheEntry(record ResourceRecord, ifkey string, now time.Time) *cacheEntry {
+	entry := &cacheEntry{
+		record:    nil,
+		ifkey:     "",
+		received:  now,
+		multicast: time.Time{},
+		expires:   now,
+		ttl:       0,
+		jitter:    0,
+		refreshes: 0,
+	}
+	entry.update(record, ifkey, now)
+	return entry
+}
+
+// update updates the entry with the specified record received now, and resets the refresh points.
+func (entry *cacheEntry) update(record ResourceRecord, ifkey string, now time.Time) {
+	entry.record = record
+	entry.ifkey = ifkey
+	entry.received = now
+	entry.ttl = time.Duration(record.TTL()) * time.Second
+	entry.expires = now.Add(entry.ttl)
+	entry.jitter = rand.Float64() * CacheRefreshJitter
+	entry.refreshes = 0
+	// RFC 6762: 10.1. Goodbye Packets
+	// Queriers receiving a record with TTL zero set the TTL to one second, and delete the record one second later.
+	if entry.ttl == 0 {
+		entry.expires = now.Add(GoodbyeTTL)
+		entry.refreshes = len(CacheRefreshRatios)
+	}
+}
+
+// expire sets the entry to expire one second later without refreshing.
+// RFC 6762: 10.2. Announcements to Flush Outdated Cache Entries
+func (entry *cacheEntry) expire(now time.Time) {
+	if expires := now.Add(GoodbyeTTL); expires.Before(entry.expires) {
+		entry.expires = expires
+	}
+	entry.refreshes = len(CacheRefreshRatios)
+}
+
+// isRefreshDue returns true if the entry has reached the next refresh point, and advances the refresh points.
+// RFC 6762: 5.2. Continuous Multicast DNS Querying
+func (entry *cacheEntry) isRefreshDue(now time.Time) bool {
+	due := false
+	for entry.refreshes < len(CacheRefreshRatios) {
+		ratio := CacheRefreshRatios[entry.refreshes] + entry.jitter
+		if now.Before(entry.received.Add(time.Duration(float64(entry.ttl) * ratio))) {
+			break
+		}
+		entry.refreshes++
+		due = true
+	}
+	return due
+}
+
+// remainingTTL returns the remaining TTL of the entry at the specified time.
+func (entry *cacheEntry) remainingTTL(now time.Time) uint {
+	return uint(entry.expires.Sub(now) / time.Second)
+}
+
+// recordAt returns a copy of the cached record with the remaining TTL at the specified time.
+// The cached record is never modified because the returned records are used outside the cache lock.
+func (entry *cacheEntry) recordAt(now time.Time) (ResourceRecord, error) {
+	recordBytes, err := entry.record.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	reader := dns.NewReaderWithBytes(recordBytes)
+	reader.SetCompressionBytes(entry.record.CompressionBytes())
+	record, err := dns.NewResourceRecordWithReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	record.SetTTL(entry.remainingTTL(now))
+	return record, nil
+}
+
+// addMessage adds the resource records of the specified response message into the cache.
+func (cache *cacheImpl) addMessage(msg Message) {
+	if msg == nil || !msg.IsResponse() {
+		return
+	}
+	ifkey := ""
+	isMulticast := false
+	if msg.To() != nil {
+		ifkey = msg.To().String()
+		isMulticast = msg.To().Transport().Is(dns.TransportMulticast)
+	}
+	cache.addRecords(ifkey, msg.ResourceRecordSet(), isMulticast)
+}
+
+// addRecords adds the specified records received on the specified interface by multicast or unicast into the cache.
+// The records of a same name, type and class received on different interfaces or in separate packets are merged.
+func (cache *cacheImpl) addRecords(ifkey string, records ResourceRecordSet, isMulticast bool) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	now := cache.now()
+	flushedKeys := map[string]bool{}
+	for _, record := range records {
+		if record.Type() == dns.OPT {
+			continue
+		}
+		key := cacheKey(record.Name(), record.Type(), record.Class())
+		entries := cache.entries[key]
+
+		// RFC 6762: 10.2. Announcements to Flush Outdated Cache Entries
+		// When a cache-flush record is received, the records with the same name, type and class which were
+		// received more than one second ago on the same interface are set to expire one second later.
+		if record.CacheFlush() && !flushedKeys[key] {
+			flushedKeys[key] = true
+			for _, entry := range entries {
+				if entry.ifkey != ifkey || now.Sub(entry.received) <= time.Second {
+					continue
+				}
+				entry.expire(now)
+			}
+		}
+
+		var entry *cacheEntry
+		idx := slices.IndexFunc(entries, func(entry *cacheEntry) bool {
+			return entry.record.Equal(record)
+		})
+		if 0 <= idx {
+			entry = entries[idx]
+			entry.update(record, ifkey, now)
+		} else {
+			entry = newCacheEntry(record, ifkey, now)
+			cache.entries[key] = append(entries, entry)
+		}
+		if isMulticast {
+			entry.multicast = now
+		}
+	}
+
+	if 0 < len(records) {
+		close(cache.updated)
+		cache.updated = make(chan struct{})
+	}
+}
+
+// updates returns the channel which is closed when any records are added into the cache next.
+func (cache *cacheImpl) updates() <-chan struct{} {
+	cache.Lock()
+	defer cache.Unlock()
+	return cache.updated
+}
+
+// nextExpiration returns the earliest expiration time of the unexpired records, or false if the cache has no unexpired records.
+func (cache *cacheImpl) nextExpiration() (time.Time, bool) {
+	cache.Lock()
+	defer cache.Unlock()
+	now := cache.now()
+	var next time.Time
+	for _, entries := range cache.entries {
+		for _, entry := range entries {
+			if !now.Before(entry.expires) {
+				continue
+			}
+			if next.IsZero() || entry.expires.Before(next) {
+				next = entry.expires
+			}
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// unexpiredEntries removes the expired entries, and returns the unexpired entries whose records match the specified filter.
+// The cache must be locked by the caller.
+func (cache *cacheImpl) unexpiredEntries(filter func(ResourceRecord) bool) []*cacheEntry {
+	now := cache.now()
+	matched := []*cacheEntry{}
+	for key, entries := range cache.entries {
+		entries = slices.DeleteFunc(entries, func(entry *cacheEntry) bool {
+			return !now.Before(entry.expires)
+		})
+		if len(entries) == 0 {
+			delete(cache.entries, key)
+			continue
+		}
+		cache.entries[key] = entries
+		for _, entry := range entries {
+			if !filter(entry.record) {
+				continue
+			}
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
+
+// entryRecords returns the copies of the records of the specified entries with the remaining TTLs.
+func (cache *cacheImpl) entryRecords(entries []*cacheEntry) ResourceRecordSet {
+	now := cache.now()
+	records := ResourceRecordSet{}
+	for _, entry := range entries {
+		record, err := entry.recordAt(now)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// unexpiredRecords removes the expired records, and returns the copies of the unexpired records which match
+// the specified filter with the remaining TTLs. The cache must be locked by the caller.
+func (cache *cacheImpl) unexpiredRecords(filter func(ResourceRecord) bool) ResourceRecordSet {
+	return cache.entryRecords(cache.unexpiredEntries(filter))
+}
+
+// questionFilter returns the filter which matches the records answering the specified question.
+func questionFilter(q dns.Question) func(ResourceRecord) bool {
+	return func(record ResourceRecord) bool {
+		return record.IsName(q.Name()) && q.Type().Equal(record.Type()) && q.Class().Equal(record.Class())
+	}
+}
+
+// knownAnswers returns the unexpired records which answer the specified questions and have more than half of their TTLs remaining.
+// RFC 6762: 7.1. Known-Answer Suppression
+// A Multicast DNS querier MUST NOT include records in the Known-Answer list whose remaining TTL is less than half of their original TTL.
+func (cache *cacheImpl) knownAnswers(questions []dns.Question) ResourceRecordSet {
+	cache.Lock()
+	defer cache.Unlock()
+
+	now := cache.now()
+	entries := []*cacheEntry{}
+	for _, q := range questions {
+		for _, entry := range cache.unexpiredEntries(questionFilter(q)) {
+			if slices.Contains(entries, entry) || entry.expires.Sub(now) <= entry.ttl/2 {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return cache.entryRecords(entries)
+}
+
+// isMulticastRecently returns true if all the cached records which answer the specified question have been received
+// by multicast within one quarter of their TTLs. It returns true if the cache has no answers to the question.
+// RFC 6762: 5.4. Questions Requesting Unicast Responses
+func (cache *cacheImpl) isMulticastRecently(q dns.Question) bool {
+	cache.Lock()
+	defer cache.Unlock()
+	now := cache.now()
+	for _, entry := range cache.unexpiredEntries(questionFilter(q)) {
+		if entry.ttl/4 <= now.Sub(entry.multicast) {
+			return false
+		}
+	}
+	return true
+}
+
+// refreshRecords returns the unexpired records which have reached the next refresh point in the records
+// interested by the specified questions, that is, the records answering the questions and the records of the services
+// which the PTR answers point to.
+// RFC 6762: 5.2. Continuous Multicast DNS Querying
+func (cache *cacheImpl) refreshRecords(questions []dns.Question) ResourceRecordSet {
+	cache.Lock()
+	defer cache.Unlock()
+
+	interestedEntries := []*cacheEntry{}
+	for _, q := range questions {
+		answers := cache.unexpiredEntries(questionFilter(q))
+		interestedEntries = append(interestedEntries, answers...)
+		for _, answer := range answers {
+			if ptr, ok := answer.record.(dns.PTRRecord); ok {
+				interestedEntries = append(interestedEntries, cache.serviceEntries(ptr.DomainName())...)
+			}
+		}
+	}
+
+	now := cache.now()
+	entries := []*cacheEntry{}
+	for _, entry := range interestedEntries {
+		if slices.Contains(entries, entry) || !entry.isRefreshDue(now) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return cache.entryRecords(entries)
+}
+
+// Records returns all unexpired records. The TTL of each record is the remaining TTL in seconds.
+func (cache *cacheImpl) Records() ResourceRecordSet {
+	cache.Lock()
+	defer cache.Unlock()
+	return cache.unexpiredRecords(func(ResourceRecord) bool { return true })
+}
+
+// LookupRecords returns the unexpired records which have the specified name, type and class.
+func (cache *cacheImpl) LookupRecords(name string, typ dns.Type, cls dns.Class) ResourceRecordSet {
+	cache.Lock()
+	defer cache.Unlock()
+	q := dns.NewQuestion(
+		dns.WithQuestionName(name),
+		dns.WithQuestionType(typ),
+		dns.WithQuestionClass(cls),
+	)
+	return cache.unexpiredRecords(questionFilter(q))
+}
+
+// serviceEntries returns the unexpired entries of the specified instance name, that is, the PTR records
+// pointing to the instance, the newest SRV and TXT records of the instance, and the address records of the target host.
+// The older SRV and TXT records are replaced by the newest ones even while they are kept for one second
+// after a cache-flush record is received, so that the service does not merge the outdated data.
+// The cache must be locked by the caller.
+// RFC 6762: 10.2. Announcements to Flush Outdated Cache Entries
+func (cache *cacheImpl) serviceEntries(name string) []*cacheEntry {
+	instanceEntries := cache.unexpiredEntries(func(record ResourceRecord) bool {
+		if ptr, ok := record.(dns.PTRRecord); ok {
+			return strings.EqualFold(ptr.DomainName(), name)
+		}
+		return record.IsName(name)
+	})
+	newestEntry := func(typ dns.Type) *cacheEntry {
+		var newest *cacheEntry
+		for _, entry := range instanceEntries {
+			if entry.record.Type() != typ {
+				continue
+			}
+			if newest == nil || !entry.received.Before(newest.received) {
+				newest = entry
+			}
+		}
+		return newest
+	}
+	entries := []*cacheEntry{}
+	for _, entry := range instanceEntries {
+		if entry.record.Type() == dns.PTR {
+			entries = append(entries, entry)
+		}
+	}
+	srvEntry := newestEntry(dns.SRV)
+	if srvEntry != nil {
+		entries = append(entries, srvEntry)
+	}
+	if txtEntry := newestEntry(dns.TXT); txtEntry != nil {
+		entries = append(entries, txtEntry)
+	}
+	if srvEntry == nil {
+		return entries
+	}
+	if srv, ok := srvEntry.record.(dns.SRVRecord); ok {
+		entries = append(entries, cache.unexpiredEntries(func(record ResourceRecord) bool {
+			return record.IsName(srv.Target()) && (record.Type() == dns.A || record.Type() == dns.AAAA)
+		})...)
+	}
+	return entries
+}
+
+// serviceRecords returns the copies of the unexpired records of the specified instance name with the remaining TTLs.
+// The cache must be locked by the caller.
+func (cache *cacheImpl) serviceRecords(name string) ResourceRecordSet {
+	return cache.entryRecords(cache.serviceEntries(name))
+}
+
+// lookupService returns the service of the specified instance name. The cache must be locked by the caller.
+func (cache *cacheImpl) lookupService(name string) (Service, bool) {
+	records := cache.serviceRecords(name)
+	if len(records) == 0 {
+		return nil, false
+	}
+	service, err := NewService(
+		WithServiceMessage(dns.NewResponseMessage(dns.WithMessageAnswers(records...))),
+	)
+	if err != nil {
+		return nil, false
+	}
+	return service, true
+}
+
+// LookupService returns the service of the specified instance name composed of the unexpired records.
+func (cache *cacheImpl) LookupService(name string) (Service, bool) {
+	cache.Lock()
+	defer cache.Unlock()
+	return cache.lookupService(name)
+}
+
+// Services returns all services composed of the unexpired records.
+func (cache *cacheImpl) Services() []Service {
+	cache.Lock()
+	defer cache.Unlock()
+	names := []string{}
+	for _, record := range cache.unexpiredRecords(func(record ResourceRecord) bool {
+		return record.Type() == dns.SRV || record.Type() == dns.TXT
+	}) {
+		if slices.ContainsFunc(names, record.IsName) {
+			continue
+		}
+		names = append(names, record.Name())
+	}
+	slices.Sort(names)
+	services := []Service{}
+	for _, name := range names {
+		if service, ok := cache.lookupService(name); ok {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
+// Flush removes all records.
+func (cache *cacheImpl) Flush() {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.entries = map[string][]*cacheEntry{}
+}
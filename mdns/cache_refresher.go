@@ -0,0 +1,136 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-logger/log"
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+const (
+	// CacheRefreshInterval is the interval to check the refresh points of the cached records.
+	CacheRefreshInterval = 100 * time.Millisecond
+)
+
+// cacheRefresher represents the questions of the active browses whose cached answers are refreshed before they expire.
+type cacheRefresher struct {
+	sync.Mutex
+	questions []dns.Question
+	done      chan struct{}
+}
+
+// newCacheRefresher returns a new cache refresher.
+func newCacheRefresher() *cacheRefresher {
+	return &cacheRefresher{
+		Mutex:     sync.Mutex{},
+		questions: []dns.Question{},
+		done:      nil,
+	}
+}
+
+// addInterest adds the specified question whose answers are refreshed.
+func (refresher *cacheRefresher) addInterest(q dns.Question) {
+	refresher.Lock()
+	defer refresher.Unlock()
+	refresher.questions = append(refresher.questions, q)
+}
+
+// removeInterest removes the specified question.
+func (refresher *cacheRefresher) removeInterest(q dns.Question) {
+	refresher.Lock()
+	defer refresher.Unlock()
+	if idx := slices.Index(refresher.questions, q); 0 <= idx {
+		refresher.questions = slices.Delete(refresher.questions, idx, idx+1)
+	}
+}
+
+// interests returns the questions whose answers are refreshed.
+func (refresher *cacheRefresher) interests() []dns.Question {
+	refresher.Lock()
+	defer refresher.Unlock()
+	return slices.Clone(refresher.questions)
+}
+
+// startRefresher calls the specified refresh function periodically until stopRefresher is called.
+func (refresher *cacheRefresher) startRefresher(refresh func()) {
+	refresher.Lock()
+	defer refresher.Unlock()
+	if refresher.done != nil {
+		return
+	}
+	done := make(chan struct{})
+	refresher.done = done
+	go func() {
+		ticker := time.NewTicker(CacheRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				refresh()
+			}
+		}
+	}()
+}
+
+// stopRefresher stops calling the refresh function.
+func (refresher *cacheRefresher) stopRefresher() {
+	refresher.Lock()
+	defer refresher.Unlock()
+	if refresher.done == nil {
+		return
+	}
+	close(refresher.done)
+	refresher.done = nil
+}
+
+// refreshQuery returns a query message to refresh the specified records.
+// RFC 6762: 5.2. Continuous Multicast DNS Querying
+func refreshQuery(records ResourceRecordSet) Message {
+	questions := []dns.Question{}
+	for _, record := range records {
+		if slices.ContainsFunc(questions, func(q dns.Question) bool {
+			return record.IsName(q.Name()) && q.Type() == record.Type()
+		}) {
+			continue
+		}
+		questions = append(questions, dns.NewQuestion(
+			dns.WithQuestionName(record.Name()),
+			dns.WithQuestionType(record.Type()),
+			dns.WithQuestionClass(dns.IN),
+		))
+	}
+	return dns.NewRequestMessage(dns.WithMessageQuestions(questions...))
+}
+
+// refreshCache sends the query for the cached records which have reached the refresh points.
+// The records which are not answered expire when their TTLs elapse.
+// RFC 6762: 5.2. Continuous Multicast DNS Querying
+func (client *clientImpl) refreshCache() {
+	records := client.cache.refreshRecords(client.interests())
+	if len(records) == 0 {
+		return
+	}
+	if err := client.AnnounceMessage(refreshQuery(records)); err != nil {
+		if client.IsRunning() {
+			log.Error(err)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+func TestCache(t *testing.T) {
+	records, err := newServiceRecords(newTestService(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newResponse := func(ifaddr string, records ...ResourceRecord) Message {
+		to, err := dns.NewAddrFromString(ifaddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dns.NewResponseMessage(
+			dns.WithMessageAnswers(records...),
+			dns.WithMessageTo(to),
+		)
+	}
+
+	// The records received in separate packets and on different interfaces are merged into one instance.
+	cache := newCache()
+	clock := newTestClock()
+	cache.now = clock.Now
+	cache.addMessage(newResponse("192.0.2.2:5353", records.LookupRecordSetByType(dns.PTR)...))
+	cache.addMessage(newResponse("192.0.2.2:5353", records.LookupRecordSetByType(dns.SRV)...))
+	cache.addMessage(newResponse("198.51.100.2:5353", records.LookupRecordSetByType(dns.TXT)...))
+	cache.addMessage(newResponse("198.51.100.2:5353", records.LookupRecordSetByType(dns.A)...))
+
+	services := cache.Services()
+	if len(services) != 1 {
+		t.Fatalf("services %d != %d", len(services), 1)
+	}
+	service := services[0]
+	if service.Port() != 8080 || service.Host() != "printer.local" || len(service.Addresses()) != 1 {
+		t.Errorf("invalid service: %s", service.String())
+	}
+	if _, ok := service.LookupResourceAttribute("path"); !ok {
+		t.Errorf("TXT attribute (path) not found")
+	}
+
+	// The cached records have the remaining TTLs.
+	srvs := cache.LookupRecords("Test Printer._http._tcp.local", dns.SRV, dns.IN)
+	if len(srvs) != 1 || DefaultHostRecordTTL < srvs[0].TTL() || srvs[0].TTL() < DefaultHostRecordTTL-1 {
+		t.Errorf("invalid remaining TTL: %v", srvs)
+	}
+
+	// The cached records are not modified by the lookups.
+	for _, srv := range records.LookupRecordSetByType(dns.SRV) {
+		if srv.TTL() != DefaultHostRecordTTL {
+			t.Errorf("cached record is modified: %d != %d", srv.TTL(), DefaultHostRecordTTL)
+		}
+	}
+
+	// RFC 6762: 10.2. Announcements to Flush Outdated Cache Entries
+	clock.Advance(GoodbyeTTL + 100*time.Millisecond)
+	a := dns.NewARecordWithAddress([]byte{192, 0, 2, 20})
+	a.SetName("printer.local")
+	a.SetTTL(DefaultHostRecordTTL)
+	a.SetCacheFlush(true)
+	cache.addMessage(newResponse("198.51.100.2:5353", a))
+
+	// RFC 6762: 10.1. Goodbye Packets
+	txt := records.LookupRecordSetByType(dns.TXT)[0]
+	txt.SetTTL(0)
+	cache.addMessage(newResponse("198.51.100.2:5353", txt))
+
+	if n := len(cache.LookupRecords("printer.local", dns.A, dns.IN)); n != 2 {
+		t.Errorf("A records %d != %d", n, 2)
+	}
+	clock.Advance(GoodbyeTTL)
+	if n := len(cache.LookupRecords("printer.local", dns.A, dns.IN)); n != 1 {
+		t.Errorf("A records %d != %d", n, 1)
+	}
+	if n := len(cache.LookupRecords("Test Printer._http._tcp.local", dns.TXT, dns.IN)); n != 0 {
+		t.Errorf("TXT records %d != %d", n, 0)
+	}
+
+	cache.Flush()
+	if n := len(cache.Records()); n != 0 {
+		t.Errorf("records %d != %d", n, 0)
+	}
+}
+
+func TestCacheRefresh(t *testing.T) {
+	srv := dns.NewSRVRecord().SetPort(8080).SetTarget("printer.local")
+	srv.SetName("Test Printer._http._tcp.local")
+	srv.SetTTL(1)
+	srv.SetCacheFlush(true)
+	ptr := dns.NewPTRRecord().SetDomainName("Test Printer._http._tcp.local")
+	ptr.SetName("_http._tcp.local")
+	ptr.SetTTL(DefaultRecordTTL)
+
+	cache := newCache()
+	clock := newTestClock()
+	cache.now = clock.Now
+	cache.addRecords("", ResourceRecordSet{ptr, srv}, true)
+	questions := []dns.Question{
+		dns.NewQuestion(
+			dns.WithQuestionName("_http._tcp.local"),
+			dns.WithQuestionType(dns.PTR),
+			dns.WithQuestionClass(dns.IN),
+		),
+	}
+
+	// RFC 6762: 5.2. Continuous Multicast DNS Querying
+	// The SRV record of the instance which the PTR answer points to is refreshed at 80%, 85%, 90% and 95% of the TTL.
+	if records := cache.refreshRecords(questions); len(records) != 0 {
+		t.Errorf("records are refreshed too early: %d", len(records))
+	}
+	clock.Advance(830 * time.Millisecond)
+	records := cache.refreshRecords(questions)
+	if len(records) != 1 || records[0].Type() != dns.SRV {
+		t.Fatalf("SRV record is not refreshed at 80%%: %v", records)
+	}
+	if records := cache.refreshRecords(questions); len(records) != 0 {
+		t.Errorf("records are refreshed twice: %d", len(records))
+	}
+	msg := refreshQuery(records)
+	if len(msg.Questions()) != 1 || msg.Questions()[0].Type() != dns.SRV {
+		t.Errorf("invalid refresh query: %v", msg)
+	}
+	if records := cache.refreshRecords(nil); len(records) != 0 {
+		t.Errorf("records without interests are refreshed: %d", len(records))
+	}
+	clock.Advance(150 * time.Millisecond)
+	if records := cache.refreshRecords(questions); len(records) != 1 {
+		t.Errorf("SRV record is not refreshed at 95%%: %v", records)
+	}
+
+	// The record expires if nobody answers.
+	clock.Advance(50 * time.Millisecond)
+	if _, ok := cache.LookupService("Test Printer._http._tcp.local"); !ok {
+		t.Fatalf("service not found")
+	}
+	if n := len(cache.LookupRecords("Test Printer._http._tcp.local", dns.SRV, dns.IN)); n != 0 {
+		t.Errorf("SRV records %d != %d", n, 0)
+	}
+}
+
+func TestCacheFlushedServiceRecords(t *testing.T) {
+	name := "Test Printer._http._tcp.local"
+	newTXT := func(version string) ResourceRecord {
+		txt := dns.NewTXTRecord().SetStrings([]string{"version=" + version})
+		txt.SetName(name)
+		txt.SetTTL(DefaultRecordTTL)
+		txt.SetCacheFlush(true)
+		return txt
+	}
+	srv := dns.NewSRVRecord().SetPort(8080).SetTarget("printer.local")
+	srv.SetName(name)
+	srv.SetTTL(DefaultHostRecordTTL)
+	srv.SetCacheFlush(true)
+
+	cache := newCache()
+	clock := newTestClock()
+	cache.now = clock.Now
+	cache.addRecords("", ResourceRecordSet{srv, newTXT("1")}, true)
+	clock.Advance(GoodbyeTTL + 100*time.Millisecond)
+	cache.addRecords("", ResourceRecordSet{newTXT("2")}, true)
+
+	// RFC 6762: 10.2. Announcements to Flush Outdated Cache Entries
+	// The flushed TXT record is kept for one second, but the service is composed of the newest TXT record only.
+	if n := len(cache.LookupRecords(name, dns.TXT, dns.IN)); n != 2 {
+		t.Errorf("TXT records %d != %d", n, 2)
+	}
+	service, ok := cache.LookupService(name)
+	if !ok {
+		t.Fatalf("service not found")
+	}
+	attrs := service.ResourceAttributes()
+	if len(attrs) != 1 || attrs[0].Value() != "2" {
+		t.Errorf("invalid attributes: %v", attrs)
+	}
+}
+
+func TestCacheKnownAnswers(t *testing.T) {
+	newPTR := func(instance string, ttl uint) ResourceRecord {
+		ptr := dns.NewPTRRecord().SetDomainName(instance)
+		ptr.SetName("_http._tcp.local")
+		ptr.SetTTL(ttl)
+		return ptr
+	}
+	srv := dns.NewSRVRecord().SetPort(8080).SetTarget("printer.local")
+	srv.SetName("Test Printer._http._tcp.local")
+	srv.SetTTL(DefaultHostRecordTTL)
+
+	cache := newCache()
+	clock := newTestClock()
+	cache.now = clock.Now
+	cache.addRecords("", ResourceRecordSet{
+		newPTR("Test Printer._http._tcp.local", DefaultRecordTTL),
+		newPTR("Short Printer._http._tcp.local", 2),
+		srv,
+	}, true)
+
+	query := NewQuery(WithQueryService("_http._tcp"))
+	questions := NewRequestWithQuery(query).Questions()
+	if n := len(cache.knownAnswers(questions)); n != 2 {
+		t.Errorf("known answers %d != %d", n, 2)
+	}
+
+	// RFC 6762: 7.1. Known-Answer Suppression
+	// The records whose remaining TTL is less than half of their original TTL are not included.
+	clock.Advance(1100 * time.Millisecond)
+	knownAnswers := cache.knownAnswers(questions)
+	if len(knownAnswers) != 1 || knownAnswers[0].Content() != "Test Printer._http._tcp.local" {
+		t.Fatalf("invalid known answers: %v", knownAnswers)
+	}
+
+	msgs := newRequestsWithKnownAnswers(query.Questions(), knownAnswers)
+	if len(msgs) != 1 || len(msgs[0].Answers()) != 1 || len(msgs[0].Questions()) != 1 {
+		t.Errorf("invalid query message: %v", msgs)
+	}
+}
+
+func TestCacheUnresolvedQuestions(t *testing.T) {
+	records, err := newServiceRecords(newTestService(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "Test Printer._http._tcp.local"
+	questionTypes := func(questions []dns.Question) []dns.Type {
+		types := []dns.Type{}
+		for _, q := range questions {
+			types = append(types, q.Type())
+		}
+		return types
+	}
+
+	// RFC 6763: 12. Additional Record Generation
+	// The SRV and TXT records are resolved first, and then the address records of the SRV target.
+	cache := newCache()
+	cache.addRecords("", records.LookupRecordSetByType(dns.PTR), true)
+	if types := questionTypes(cache.unresolvedQuestions(name)); !slices.Equal(types, []dns.Type{dns.SRV, dns.TXT}) {
+		t.Errorf("invalid questions: %v", types)
+	}
+	cache.addRecords("", records.LookupRecordSetByType(dns.SRV), true)
+	if types := questionTypes(cache.unresolvedQuestions(name)); !slices.Equal(types, []dns.Type{dns.TXT}) {
+		t.Errorf("invalid questions: %v", types)
+	}
+	cache.addRecords("", records.LookupRecordSetByType(dns.TXT), true)
+	questions := cache.unresolvedQuestions(name)
+	if types := questionTypes(questions); !slices.Equal(types, []dns.Type{dns.A, dns.AAAA}) {
+		t.Errorf("invalid questions: %v", types)
+	}
+	for _, q := range questions {
+		if q.Name() != "printer.local" {
+			t.Errorf("invalid question name: %s", q.Name())
+		}
+	}
+	cache.addRecords("", records.LookupRecordSetByType(dns.AAAA), true)
+	if questions := cache.unresolvedQuestions(name); len(questions) != 0 {
+		t.Errorf("questions %d != %d", len(questions), 0)
+	}
+}
@@ -16,6 +16,7 @@ package mdns
 
 import (
 	"context"
+	"net/netip"
 )
 
 // Client represents a client node instance.
@@ -32,4 +33,16 @@ type Client interface {
 	UnRegisterMessageHandler(handler MessageHandler)
 	// Query sends a question message to the multicast address.
 	Query(ctx context.Context, query Query) ([]Service, error)
+	// QueryStream sends a question message to the multicast address, and notifies the services as soon as they are found, or returns an error if the message cannot be sent.
+	QueryStream(ctx context.Context, query Query) (<-chan Service, error)
+	// Browse sends question messages to the multicast address continuously, and notifies the service events until the context is done.
+	Browse(ctx context.Context, query Query) (<-chan ServiceEvent, error)
+	// Resolve sends the follow-up queries to resolve the SRV, TXT and address records of the specified service instance name.
+	Resolve(ctx context.Context, name string) (Service, error)
+	// LookupHost sends the A and AAAA queries of the specified host name, and returns the addresses of the host.
+	LookupHost(ctx context.Context, host string) ([]netip.Addr, error)
+	// LookupAddr sends the reverse mapping PTR query of the specified address, and returns the host names of the address.
+	LookupAddr(ctx context.Context, addr netip.Addr) ([]string, error)
+	// Cache returns the record cache which holds the records received from other hosts.
+	Cache() Cache
 }
@@ -16,7 +16,9 @@ package mdns
 
 import (
 	"context"
-	"sync"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/cybergarage/go-logger/log"
 	"github.com/cybergarage/go-mdns/mdns/dns"
@@ -25,22 +27,31 @@ import (
 
 // clientImpl represents a client node instance.
 type clientImpl struct {
-	sync.Mutex
 	*transport.MessageManager
-	*serviceSet
 	*msgHandler
+	*cacheRefresher
+	*questionSuppressor
+	*queryCollectorSet
+	cache *cacheImpl
 }
 
 // NewClient returns a new client instance.
 func NewClient() Client {
 	client := &clientImpl{
-		Mutex:          sync.Mutex{},
-		MessageManager: transport.NewMessageManager(),
-		serviceSet:     newServiceSet(),
-		msgHandler:     newMessageHandler(),
+		MessageManager:     transport.NewMessageManager(),
+		msgHandler:         newMessageHandler(),
+		cacheRefresher:     newCacheRefresher(),
+		questionSuppressor: newQuestionSuppressor(),
+		queryCollectorSet:  newQueryCollectorSet(),
+		cache:              newCache(),
 	}
 	client.MessageManager.SetMessageProcessor(
 		func(msg dns.Message) (dns.Message, error) {
+			if msg.IsQuery() {
+				client.queryReceived(msg)
+			}
+			client.cache.addMessage(msg)
+			client.collectMessage(msg)
 			client.processMessageHandlers(msg)
 			return nil, nil
 		})
@@ -53,11 +64,16 @@ func (client *clientImpl) Start() error {
 	if err := client.Stop(); err != nil {
 		return err
 	}
-	return client.MessageManager.Start()
+	if err := client.MessageManager.Start(); err != nil {
+		return err
+	}
+	client.startRefresher(client.refreshCache)
+	return nil
 }
 
 // Stop stops the client instance.
 func (client *clientImpl) Stop() error {
+	client.stopRefresher()
 	return client.MessageManager.Stop()
 }
 
@@ -69,63 +85,173 @@ func (client *clientImpl) Restart() error {
 	return client.Start()
 }
 
-// Query sends a question message to the multicast address.
+// Cache returns the record cache which holds the records received from other hosts.
+func (client *clientImpl) Cache() Cache {
+	return client.cache
+}
+
+// Query sends a question message to the multicast address, and returns the services which answer the query
+// from the record cache after the context is done, the maximum number of the services are found,
+// or no new services are found within the idle timeout of the query.
+// The concurrent queries share the sockets and the record cache, but each query collects its own answers.
 func (client *clientImpl) Query(ctx context.Context, q Query) ([]Service, error) {
-	client.Lock()
-	defer client.Unlock()
+	collector, questions, err := client.sendQuery(q)
+	if err != nil {
+		return []Service{}, err
+	}
+	names := []string{}
+	client.collectQuery(ctx, q, collector, questions, func(name string, _ Service) bool {
+		names = append(names, name)
+		return true
+	})
+
+	// The services are looked up again to return the records received after the services are found.
+	services := []Service{}
+	for _, name := range names {
+		service, ok := client.cache.LookupService(name)
+		if !ok {
+			continue
+		}
+		log.Debugf("mDNS Service responded: %s", service.String())
+		services = append(services, service)
+	}
+	return services, nil
+}
 
-	client.serviceSet.Clear()
+// QueryStream sends a question message to the multicast address, and notifies the services which answer the query
+// into the returned channel as soon as they are found. The channel is closed when the query ends as Query does.
+// An error is returned if the question message cannot be sent, and then no channel is returned.
+func (client *clientImpl) QueryStream(ctx context.Context, q Query) (<-chan Service, error) {
+	collector, questions, err := client.sendQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	services := make(chan Service)
+	go func() {
+		defer close(services)
+		client.collectQuery(ctx, q, collector, questions, func(_ string, service Service) bool {
+			select {
+			case services <- service:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return services, nil
+}
+
+// sendQuery adds a new collector of the specified query, and sends the first question messages of the query.
+// It returns the collector and the sent questions, and the collector is removed if the messages cannot be sent.
+func (client *clientImpl) sendQuery(q Query) (*queryCollector, []dns.Question, error) {
+	handler, _ := q.MessageHandler()
+	collector := newQueryCollector(q.Questions(), handler)
+	client.addCollector(collector)
 
+	queryMsgs := client.queryMessages(newQueryScheduler(q, client.cache))
+	if err := client.sendQueryMessages(queryMsgs); err != nil {
+		client.removeCollector(collector)
+		return nil, nil, err
+	}
+	return collector, queryMsgs[0].Questions(), nil
+}
+
+// collectQuery calls the specified function with the instance name and the service of each new service which answers
+// the sent questions and matches the filters of the query until the function returns false, the context is done,
+// the maximum number of the services are found, or no new services are found within the idle timeout.
+// The specified collector is removed when the query ends.
+func (client *clientImpl) collectQuery(ctx context.Context, q Query, collector *queryCollector, questions []dns.Question, found func(string, Service) bool) {
+	defer client.removeCollector(collector)
 	if _, ok := ctx.Deadline(); !ok {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, DefaultQueryTimeout)
 		defer cancel()
 	}
 
-	handler, ok := q.MessageHandler()
-	if ok {
-		client.RegisterMessageHandler(handler)
-		defer client.UnRegisterMessageHandler(handler)
+	// The idle timeout is restarted whenever a new service is found.
+	var idleTimeout <-chan time.Time
+	if 0 < q.IdleTimeout() {
+		idleTimeout = time.After(q.IdleTimeout())
 	}
 
-	queryMsg := NewRequestWithQuery(q)
-
-	respondServices := newServiceSet()
-	queryResponseHandler := func(resMsg dns.Message) {
-		if !resMsg.IsResponse() {
-			return
-		}
-		if queryMsg.IsQueryWithUnicastResponse() {
-			if resMsg.From().Transport().Is(dns.TransportMulticast) {
+	foundNames := map[string]bool{}
+	for {
+		updates := client.cache.updates()
+		isFound := false
+		for _, name := range client.answeredNames(questions, collector.collectedNames()...) {
+			if foundNames[strings.ToLower(name)] {
+				continue
+			}
+			service, ok := client.cache.LookupService(name)
+			if !ok || !q.MatchService(service) {
+				continue
+			}
+			foundNames[strings.ToLower(name)] = true
+			isFound = true
+			if !found(name, service) {
+				return
+			}
+			if 0 < q.MaxResults() && q.MaxResults() <= len(foundNames) {
 				return
 			}
 		}
-
-		newService, err := NewService(
-			WithServiceMessage(resMsg),
-		)
-		if err != nil {
+		if isFound && 0 < q.IdleTimeout() {
+			idleTimeout = time.After(q.IdleTimeout())
+		}
+		select {
+		case <-ctx.Done():
 			return
+		case <-idleTimeout:
+			return
+		case <-updates:
 		}
+	}
+}
 
-		added := false
-		if queryMsg.IsQueryAnswer(resMsg) {
-			added = respondServices.AddService(newService)
+// queryMessages returns the next query messages scheduled by the specified scheduler with the known answers of the query
+// and the cached answers which have more than half of their TTLs remaining.
+// RFC 6762: 7.1. Known-Answer Suppression
+func (client *clientImpl) queryMessages(scheduler *queryScheduler) []Message {
+	questions := scheduler.nextQuestions()
+	knownAnswers := slices.Clone(scheduler.query.KnownAnswers())
+	for _, answer := range client.cache.knownAnswers(questions) {
+		if slices.ContainsFunc(knownAnswers, answer.Equal) {
+			continue
 		}
-
-		log.Debugf("mDNS Service responded: %s (added=%t)", newService.String(), added)
+		knownAnswers = append(knownAnswers, answer)
 	}
-	client.RegisterMessageHandler(queryResponseHandler)
-	defer client.UnRegisterMessageHandler(queryResponseHandler)
+	return newRequestsWithKnownAnswers(questions, knownAnswers)
+}
 
-	err := client.AnnounceMessage(queryMsg)
-	if err != nil {
-		return []Service{}, err
+// sendQueryMessages sends the specified query messages to the multicast address.
+// RFC 6762: 7.2. Multipacket Known-Answer Suppression
+func (client *clientImpl) sendQueryMessages(queryMsgs []Message) error {
+	for _, msg := range queryMsgs {
+		client.querySent(msg)
+		if err := client.AnnounceMessage(msg); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	<-ctx.Done()
-
-	client.AddServices(respondServices.Services())
-
-	return respondServices.Services(), nil
+// answeredNames returns the names of the cached records which answer the specified questions, and the specified collected names.
+// The names are the instance names which the PTR answers point to, or the names of the other answers.
+func (client *clientImpl) answeredNames(questions []dns.Question, collectedNames ...string) []string {
+	names := []string{}
+	addName := func(name string) {
+		if slices.ContainsFunc(names, func(other string) bool { return strings.EqualFold(other, name) }) {
+			return
+		}
+		names = append(names, name)
+	}
+	for _, q := range questions {
+		for _, record := range client.cache.LookupRecords(q.Name(), q.Type(), q.Class()) {
+			addName(answerName(record))
+		}
+	}
+	for _, name := range collectedNames {
+		addName(name)
+	}
+	return names
 }
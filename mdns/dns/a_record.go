@@ -21,6 +21,8 @@ import (
 // ARecord represents a A record interface.
 type ARecord interface {
 	Record
+	// SetAddress sets the resource ip address.
+	SetAddress(ip net.IP) ARecord
 	// Address returns the resource ip address.
 	Address() net.IP
 	// Content returns a string representation to the record data.
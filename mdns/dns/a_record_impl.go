@@ -30,6 +30,14 @@ func NewARecord(res *record) ARecord {
 	}
 }
 
+// NewARecordWithAddress returns a new A record instance with the specified address.
+func NewARecordWithAddress(ip net.IP) ARecord {
+	a := &aRecord{
+		record: newRecord(withRecordType(A), withRecordClass(IN)),
+	}
+	return a.SetAddress(ip)
+}
+
 // newARecordWithResourceRecord returns a new A record instance.
 func newARecordWithResourceRecord(res *record) ARecord {
 	return &aRecord{
@@ -37,6 +45,15 @@ func newARecordWithResourceRecord(res *record) ARecord {
 	}
 }
 
+// SetAddress sets the resource ip address.
+func (a *aRecord) SetAddress(ip net.IP) ARecord {
+	a.data = nil
+	if ip4 := ip.To4(); ip4 != nil {
+		a.data = []byte(ip4)
+	}
+	return a
+}
+
 // Address returns the resource ip address.
 func (a *aRecord) Address() net.IP {
 	if len(a.data) < 4 {
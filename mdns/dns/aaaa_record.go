@@ -18,6 +18,8 @@ import "net"
 
 type AAAARecord interface {
 	Record
+	// SetAddress sets the resource ip address.
+	SetAddress(ip net.IP) AAAARecord
 	// Address returns the resource ip address.
 	Address() net.IP
 	// Content returns a string representation to the record data.
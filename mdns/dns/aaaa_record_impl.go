@@ -28,7 +28,7 @@ type aaaaRecord struct {
 // NewAAAARecord returns a new AAAA record instance.
 func NewAAAARecord() AAAARecord {
 	return &aaaaRecord{
-		record: newRecord(),
+		record: newRecord(withRecordType(AAAA), withRecordClass(IN)),
 	}
 }
 
@@ -39,6 +39,15 @@ func newAAAARecordWithResourceRecord(res *record) AAAARecord {
 	}
 }
 
+// SetAddress sets the resource ip address.
+func (a *aaaaRecord) SetAddress(ip net.IP) AAAARecord {
+	a.data = nil
+	if ip16 := ip.To16(); ip16 != nil {
+		a.data = []byte(ip16)
+	}
+	return a
+}
+
 // Address returns the resource ip address.
 func (a *aaaaRecord) Address() net.IP {
 	if len(a.data) != 16 {
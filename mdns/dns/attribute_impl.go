@@ -36,6 +36,14 @@ func NewAttribute() Attribute {
 	}
 }
 
+// NewAttributeWithNameValue returns a new attribute instance with the specified name and value.
+func NewAttributeWithNameValue(name string, value string) Attribute {
+	return &attrImpl{
+		name:  name,
+		value: value,
+	}
+}
+
 func newAttribute() *attrImpl {
 	return &attrImpl{
 		name:  "",
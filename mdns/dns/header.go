@@ -121,6 +121,16 @@ func (header *Header) ID() uint {
 	return encoding.BytesToInteger(header.bytes[:2])
 }
 
+// SetID sets the specified query identifier.
+// RFC 6762: 18.1. ID (Query Identifier)
+// In unicast response messages generated specifically in response to a particular (unicast or multicast) query, the Query Identifier MUST match the ID from the query message.
+func (header *Header) SetID(id uint) {
+	bytes := make([]byte, 2)
+	encoding.IntegerToBytes(id, bytes)
+	header.bytes[0] = bytes[0]
+	header.bytes[1] = bytes[1]
+}
+
 // QR returns the query type.
 // RFC 6762: 18.2. QR (Query/Response) Bit
 // In query messages the QR bit MUST be zero. In response messages the QR bit MUST be one.
@@ -154,6 +164,17 @@ func (header *Header) TC() bool {
 	return (header.bytes[2] & 0x02) == 0x02
 }
 
+// SetTC sets the specified truncated bit.
+// RFC 6762: 7.2. Multipacket Known-Answer Suppression
+// If the known answers do not fit in a single query packet, the querier sets the TC bit, and sends the rest in the following packets.
+func (header *Header) SetTC(flag bool) {
+	if flag {
+		header.bytes[2] |= 0x02
+	} else {
+		header.bytes[2] &^= 0x02
+	}
+}
+
 // RD returns the recursion desired bit.
 // RFC 6762: 18.6. RD (Recursion Desired) Bit
 // In both multicast query and multicast response messages, the Recursion Desired bit SHOULD be zero on transmission, and MUST be ignored on reception.
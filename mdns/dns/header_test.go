@@ -156,4 +156,30 @@ func TestHeader(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("SetID", func(t *testing.T) {
+		header := NewResponseHeader()
+		header.SetID(0x1234)
+		if header.ID() != 0x1234 {
+			t.Errorf("%X != %X", header.ID(), 0x1234)
+		}
+		if !header.IsResponse() || !header.AA() {
+			t.Errorf("flags are changed: %s", header.String())
+		}
+	})
+
+	t.Run("SetTC", func(t *testing.T) {
+		header := NewRequestHeader()
+		header.SetTC(true)
+		if !header.TC() {
+			t.Errorf("%t != %t", header.TC(), true)
+		}
+		if !header.IsQuery() || header.RD() {
+			t.Errorf("flags are changed: %s", header.String())
+		}
+		header.SetTC(false)
+		if header.TC() {
+			t.Errorf("%t != %t", header.TC(), false)
+		}
+	})
 }
@@ -25,6 +25,8 @@ type MessageOption func(*message) error
 type Message interface {
 	// From returns the source address of the message.
 	From() Addr
+	// To returns the local address which received the message, or the destination address to send the message by unicast.
+	To() Addr
 	// Flags returns the flags.
 	Flags() []byte
 	// ID returns the query identifier.
@@ -120,6 +122,8 @@ type MessageHelper interface {
 	IsQueryWithUnicastResponse() bool
 	// IsQueryAnswer returns true if the message is a response to a query, otherwise false.
 	IsQueryAnswer(msg Message) bool
+	// AnsweredQuestions returns the questions of the query which the specified response message answers.
+	AnsweredQuestions(msg Message) Questions
 	// LookupResourceRecordByNamePrefix returns the resource record of the specified name prefix.
 	LookupResourceRecordByNamePrefix(prefix string) (ResourceRecord, bool)
 	// LookupResourceRecordByNameSuffix returns the resource record of the specified name suffix.
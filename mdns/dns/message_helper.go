@@ -31,32 +31,43 @@ func (msg *message) IsQueryWithUnicastResponse() bool {
 }
 
 // IsQueryAnswer returns true if the message is a response to a query, otherwise false.
+// The response answers the query if it answers any question of the query.
 func (msg *message) IsQueryAnswer(resMsg Message) bool {
 	if msg == nil {
 		return true
 	}
-	if resMsg == nil {
-		return false
+	return 0 < len(msg.AnsweredQuestions(resMsg))
+}
+
+// AnsweredQuestions returns the questions of the query which the specified response message answers.
+// A question is answered by the records which match the name, type and class of the question,
+// or by the NSEC record which asserts that the name has no record of the type.
+// RFC 6762: 6.1. Negative Responses
+func (msg *message) AnsweredQuestions(resMsg Message) Questions {
+	questions := Questions{}
+	if msg == nil || resMsg == nil {
+		return questions
 	}
 	if !msg.IsQuery() || !resMsg.IsResponse() {
-		return false
+		return questions
 	}
 	if msg.ID() != 0 && resMsg.ID() != 0 && msg.ID() != resMsg.ID() {
-		return false
+		return questions
 	}
 	for _, q := range msg.Questions() {
 		for _, rr := range resMsg.ResourceRecordSet() {
-			if !rr.IsName(q.Name()) {
+			if !rr.IsName(q.Name()) || !q.Class().Equal(rr.Class()) {
 				continue
 			}
-			if !q.Class().Equal(rr.Class()) {
-				continue
+			if nsec, ok := rr.(NSECRecord); ok && !nsec.HasType(q.Type()) && q.Type() != NSEC {
+				questions = append(questions, q)
+				break
 			}
-			if !q.Type().Equal(rr.Type()) {
-				continue
+			if q.Type().Equal(rr.Type()) {
+				questions = append(questions, q)
+				break
 			}
-			return true
 		}
 	}
-	return false
+	return questions
 }
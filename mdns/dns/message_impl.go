@@ -23,6 +23,7 @@ import (
 type message struct {
 	*Header
 	from        Addr
+	to          Addr
 	pktBytes    []byte
 	questions   Questions
 	answers     Answers
@@ -34,6 +35,7 @@ func newMessage(opts ...MessageOption) *message {
 	msg := &message{
 		Header:      NewHeader(),
 		from:        nil,
+		to:          nil,
 		pktBytes:    nil,
 		questions:   Questions{},
 		answers:     Answers{},
@@ -56,6 +58,52 @@ func WithMessageQuestions(questions ...Question) MessageOption {
 	}
 }
 
+// WithMessageAnswers returns a message option with the specified answers.
+func WithMessageAnswers(answers ...Answer) MessageOption {
+	return func(msg *message) error {
+		for _, a := range answers {
+			msg.AddAnswer(a)
+		}
+		return nil
+	}
+}
+
+// WithMessageNameServers returns a message option with the specified authority records.
+func WithMessageNameServers(nameServers ...NameServer) MessageOption {
+	return func(msg *message) error {
+		for _, ns := range nameServers {
+			msg.AddNameServer(ns)
+		}
+		return nil
+	}
+}
+
+// WithMessageAdditions returns a message option with the specified additional records.
+func WithMessageAdditions(additions ...Addition) MessageOption {
+	return func(msg *message) error {
+		for _, a := range additions {
+			msg.AddAddition(a)
+		}
+		return nil
+	}
+}
+
+// WithMessageID returns a message option with the specified query identifier.
+func WithMessageID(id uint) MessageOption {
+	return func(msg *message) error {
+		msg.SetID(id)
+		return nil
+	}
+}
+
+// WithMessageTC returns a message option with the specified truncated bit.
+func WithMessageTC(flag bool) MessageOption {
+	return func(msg *message) error {
+		msg.SetTC(flag)
+		return nil
+	}
+}
+
 // WithMessageFrom returns a message option with the specified source address.
 func WithMessageFrom(addr Addr) MessageOption {
 	return func(msg *message) error {
@@ -64,6 +112,15 @@ func WithMessageFrom(addr Addr) MessageOption {
 	}
 }
 
+// WithMessageTo returns a message option with the specified local address which received the message,
+// or the destination address to send the message by unicast.
+func WithMessageTo(addr Addr) MessageOption {
+	return func(msg *message) error {
+		msg.to = addr
+		return nil
+	}
+}
+
 // NewMessage returns a nil message instance.
 func NewMessage(opts ...MessageOption) Message {
 	return newMessage(opts...)
@@ -109,6 +166,14 @@ func (msg *message) From() Addr {
 	return msg.from
 }
 
+// To returns the local address which received the message, or the destination address to send the message by unicast.
+func (msg *message) To() Addr {
+	if msg == nil {
+		return nil
+	}
+	return msg.to
+}
+
 // AddQuestion adds the specified question into the message.
 func (msg *message) AddQuestion(q Question) {
 	msg.questions = append(msg.questions, q)
@@ -260,6 +325,7 @@ func (msg *message) Copy() Message {
 	return &message{
 		Header:      NewHeaderWithBytes(msg.Header.bytes),
 		from:        msg.from,
+		to:          msg.to,
 		pktBytes:    msg.pktBytes,
 		questions:   msg.questions,
 		answers:     msg.answers,
@@ -15,9 +15,60 @@
 package dns
 
 import (
+	"slices"
 	"testing"
 )
 
 func TestNewMessage(t *testing.T) {
 	NewRequestMessage()
 }
+
+func TestMessageAnsweredQuestions(t *testing.T) {
+	newQuestion := func(name string, typ Type) Question {
+		return NewQuestion(
+			WithQuestionName(name),
+			WithQuestionType(typ),
+			WithQuestionClass(IN),
+		)
+	}
+	query := NewRequestMessage(WithMessageQuestions(
+		newQuestion("_http._tcp.local", PTR),
+		newQuestion("printer.local", A),
+		newQuestion("printer.local", AAAA),
+		newQuestion("scanner.local", A),
+	))
+
+	ptr := NewPTRRecord().SetDomainName("Test Printer._http._tcp.local")
+	ptr.SetName("_http._tcp.local")
+	a := NewARecordWithAddress([]byte{192, 0, 2, 10})
+	a.SetName("printer.local")
+	// RFC 6762: 6.1. Negative Responses
+	nsec := NewNSECRecord().SetNextDomainName("printer.local").SetTypes(A)
+	nsec.SetName("printer.local")
+
+	tests := []struct {
+		name     string
+		records  []ResourceRecord
+		expected []Type
+	}{
+		{"None", []ResourceRecord{}, []Type{}},
+		{"PTR", []ResourceRecord{ptr}, []Type{PTR}},
+		{"A", []ResourceRecord{a}, []Type{A}},
+		{"Negative", []ResourceRecord{a, nsec}, []Type{A, AAAA}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := NewResponseMessage(WithMessageAnswers(test.records...))
+			types := []Type{}
+			for _, q := range query.AnsweredQuestions(res) {
+				types = append(types, q.Type())
+			}
+			if !slices.Equal(types, test.expected) {
+				t.Errorf("answered questions %v != %v", types, test.expected)
+			}
+			if query.IsQueryAnswer(res) != (0 < len(test.expected)) {
+				t.Errorf("query answer %t", query.IsQueryAnswer(res))
+			}
+		})
+	}
+}
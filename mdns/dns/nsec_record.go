@@ -19,6 +19,16 @@ package dns
 // https://www.rfc-editor.org/rfc/rfc4034
 type NSECRecord interface {
 	Record
+	// SetNextDomainName sets the next owner name in the canonical ordering of the zone.
+	SetNextDomainName(name string) NSECRecord
+	// SetTypes sets the record types which exist at the owner name.
+	SetTypes(types ...Type) NSECRecord
+	// NextDomainName returns the next owner name in the canonical ordering of the zone.
+	NextDomainName() string
+	// Types returns the record types which exist at the owner name.
+	Types() []Type
+	// HasType returns true if the specified record type exists at the owner name, otherwise false.
+	HasType(typ Type) bool
 	// Content returns a string representation to the record data.
 	Content() string
 }
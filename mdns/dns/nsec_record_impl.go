@@ -14,46 +14,172 @@
 
 package dns
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	nsecMaxBitmapLength = 32
+)
+
 // nsecRecord represents a NSEC record.
 // RFC 4034: Resource Records for the DNS Security Extensions.
 // https://www.rfc-editor.org/rfc/rfc4034
 type nsecRecord struct {
 	*record
+	nextDomainName string
+	types          []Type
 }
 
 // NewNSECRecord returns a new NSEC record instance.
 func NewNSECRecord() NSECRecord {
 	return &nsecRecord{
-		record: newRecord(),
+		record:         newRecord(withRecordType(NSEC), withRecordClass(IN)),
+		nextDomainName: "",
+		types:          []Type{},
 	}
 }
 
 // newNSECRecordWithResourceRecord returns a new NSEC record instance.
 func newNSECRecordWithResourceRecord(res *record) (NSECRecord, error) {
 	nsec := &nsecRecord{
-		record: res,
+		record:         res,
+		nextDomainName: "",
+		types:          []Type{},
 	}
 	return nsec, nsec.parseResourceRecord()
 }
 
+// RFC 4034: 4.1. NSEC RDATA Wire Format
+// The RDATA of the NSEC RR consists of the next domain name and the type bit maps.
+// RFC 4034: 4.1.2. The Type Bit Maps Field
+// The RR type space is split into 256 window blocks, each representing the low-order 8 bits of the 16-bit RR type space.
+// Each block that has at least one active RR type is encoded using a single octet window number (from 0 to 255),
+// a single octet bitmap length (from 1 to 32) indicating the number of octets used for the window block's bitmap,
+// and up to 32 octets (256 bits) of bitmap.
 func (nsec *nsecRecord) parseResourceRecord() error {
 	if len(nsec.data) == 0 {
 		return nil
 	}
 
-	// var err error
+	var err error
+
+	reader := NewReaderWithBytes(nsec.data)
 
-	// reader := NewReaderWithBytes(nsec.data)
+	// RFC 6762: 18.14. Name Compression
+	// Some implementations compress the next domain name, so it is decoded with the message bytes.
+	// RFC 6762: 6.1. Negative Responses
+	// In Multicast DNS, the 'Next Domain Name' field contains the record's own name,
+	// so the owner name is used when the compressed name can not be decoded.
+	reader.SetCompressionBytes(nsec.CompressionBytes())
+	nsec.nextDomainName, err = reader.ReadName()
+	if err != nil {
+		nsec.nextDomainName = nsec.Name()
+	}
+
+	types := []Type{}
+	for {
+		window, err := reader.ReadUint8()
+		if err != nil {
+			break
+		}
+		bitmapLen, err := reader.ReadUint8()
+		if err != nil || bitmapLen == 0 || nsecMaxBitmapLength < bitmapLen {
+			return fmt.Errorf("%w NSEC type bitmap: %s", ErrInvalid, nsec.Name())
+		}
+		bitmap := make([]byte, bitmapLen)
+		if n, err := reader.Read(bitmap); err != nil || n != len(bitmap) {
+			return fmt.Errorf("%w NSEC type bitmap: %s", ErrInvalid, nsec.Name())
+		}
+		for i, b := range bitmap {
+			for bit := range 8 {
+				if (b & (0x80 >> bit)) == 0 {
+					continue
+				}
+				types = append(types, Type(uint(window)<<8|uint(i*8+bit)))
+			}
+		}
+	}
+	nsec.types = types
 
 	return nil
 }
 
+// updateData updates the record data with the current fields.
+func (nsec *nsecRecord) updateData() {
+	w := NewWriter()
+	w.WriteBytes(nameToBytes(nsec.nextDomainName))
+	windows := map[byte][]byte{}
+	for _, typ := range nsec.types {
+		window := byte(typ >> 8)
+		bit := byte(typ & 0xFF)
+		bitmap := windows[window]
+		if n := int(bit/8) + 1; len(bitmap) < n {
+			bitmap = append(bitmap, make([]byte, n-len(bitmap))...)
+		}
+		bitmap[bit/8] |= 0x80 >> (bit % 8)
+		windows[window] = bitmap
+	}
+	for window := range 256 {
+		bitmap, ok := windows[byte(window)]
+		if !ok {
+			continue
+		}
+		w.WriteUint8(uint8(window))
+		w.WriteUint8(uint8(len(bitmap)))
+		w.WriteBytes(bitmap)
+	}
+	nsec.data = w.Bytes()
+}
+
+// SetNextDomainName sets the next owner name in the canonical ordering of the zone.
+func (nsec *nsecRecord) SetNextDomainName(name string) NSECRecord {
+	nsec.nextDomainName = name
+	nsec.updateData()
+	return nsec
+}
+
+// SetTypes sets the record types which exist at the owner name.
+func (nsec *nsecRecord) SetTypes(types ...Type) NSECRecord {
+	nsec.types = []Type{}
+	for _, typ := range types {
+		if slices.Contains(nsec.types, typ) {
+			continue
+		}
+		nsec.types = append(nsec.types, typ)
+	}
+	slices.Sort(nsec.types)
+	nsec.updateData()
+	return nsec
+}
+
+// NextDomainName returns the next owner name in the canonical ordering of the zone.
+func (nsec *nsecRecord) NextDomainName() string {
+	return nsec.nextDomainName
+}
+
+// Types returns the record types which exist at the owner name.
+func (nsec *nsecRecord) Types() []Type {
+	return nsec.types
+}
+
+// HasType returns true if the specified record type exists at the owner name, otherwise false.
+func (nsec *nsecRecord) HasType(typ Type) bool {
+	return slices.Contains(nsec.types, typ)
+}
+
 // Content returns a string representation to the record data.
 func (nsec *nsecRecord) Content() string {
 	if len(nsec.data) == 0 {
 		return ""
 	}
-	return ""
+	strs := []string{nsec.nextDomainName}
+	for _, typ := range nsec.types {
+		strs = append(strs, typ.String())
+	}
+	return strings.Join(strs, " ")
 }
 
 // Equal returns true if this record is equal to  the specified resource record. otherwise false.
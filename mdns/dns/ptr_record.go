@@ -16,6 +16,8 @@ package dns
 
 type PTRRecord interface {
 	Record
+	// SetDomainName sets the domain name pointed to by this PTR record.
+	SetDomainName(name string) PTRRecord
 	// DomainName returns the domain name pointed to by this PTR record.
 	DomainName() string
 	// Content returns a string representation to the record data.
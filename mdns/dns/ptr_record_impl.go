@@ -23,7 +23,7 @@ type ptrRecord struct {
 // NewPTRRecord returns a new PTR record instance.
 func NewPTRRecord() PTRRecord {
 	return &ptrRecord{
-		record:     newRecord(),
+		record:     newRecord(withRecordType(PTR), withRecordClass(IN)),
 		domainName: "",
 	}
 }
@@ -48,6 +48,13 @@ func (ptr *ptrRecord) parseResourceRecord() error {
 	return err
 }
 
+// SetDomainName sets the resource domain name.
+func (ptr *ptrRecord) SetDomainName(name string) PTRRecord {
+	ptr.domainName = name
+	ptr.data = nameToBytes(name)
+	return ptr
+}
+
 // DomainName returns the resource domain name.
 func (ptr *ptrRecord) DomainName() string {
 	return ptr.domainName
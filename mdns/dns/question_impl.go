@@ -72,7 +72,7 @@ func NewQuestionWithReader(reader *Reader) (Question, error) {
 
 // IsUnicastResponse returns true if the question has the unicast response bit set, otherwise false.
 func (q *question) IsUnicastResponse() bool {
-	return (q.Class() & QU) == QU
+	return q.UnicastResponse() || q.Class().IsUnicastResponse()
 }
 
 // Equal returns true if this record is equal to  the specified resource record. otherwise false.
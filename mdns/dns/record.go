@@ -42,6 +42,12 @@ type Record interface {
 	SetUnicastResponse(flag bool) Record
 	// UnicastResponse returns the unicast response flag.
 	UnicastResponse() bool
+	// SetCacheFlush sets the cache-flush flag.
+	// RFC 6762: 10.2. Announcements to Flush Outdated Cache Entries
+	// In resource records, the top bit of the rrclass field is the cache-flush bit, which shares the bit position with the unicast response bit of questions.
+	SetCacheFlush(flag bool) Record
+	// CacheFlush returns the cache-flush flag.
+	CacheFlush() bool
 	// SetData sets the  record data.
 	SetData(data []byte) Record
 	// Data returns the record data.
@@ -15,6 +15,8 @@
 package dns
 
 import (
+	"bytes"
+	"cmp"
 	"fmt"
 	"strings"
 	"unicode"
@@ -25,6 +27,20 @@ import (
 // recordOptions represents a record option.
 type recordOptions func(*record)
 
+// withRecordType returns a record option with the specified type.
+func withRecordType(typ Type) recordOptions {
+	return func(r *record) {
+		r.typ = typ
+	}
+}
+
+// withRecordClass returns a record option with the specified class.
+func withRecordClass(cls Class) recordOptions {
+	return func(r *record) {
+		r.class = cls
+	}
+}
+
 // record represents a base record.
 type record struct {
 	reader          *Reader
@@ -115,6 +131,12 @@ func (r *record) SetUnicastResponse(enabled bool) Record {
 	return r
 }
 
+// SetCacheFlush sets the specified cache-flush flag.
+func (r *record) SetCacheFlush(enabled bool) Record {
+	r.unicastResponse = enabled
+	return r
+}
+
 // SetType sets the specified resource record type.
 func (r *record) SetType(typ Type) Record {
 	r.typ = typ
@@ -170,6 +192,11 @@ func (r *record) UnicastResponse() bool {
 	return r.unicastResponse
 }
 
+// CacheFlush returns the cache-flush flag.
+func (r *record) CacheFlush() bool {
+	return r.unicastResponse
+}
+
 // Class returns the resource record class.
 func (r *record) Class() Class {
 	return r.class
@@ -315,6 +342,36 @@ func (r *record) CompressionBytes() []byte {
 	return r.cmpBytes
 }
 
+// canonicalData returns the record data with the uncompressed domain names.
+func canonicalData(r Record) []byte {
+	switch rr := r.(type) {
+	case PTRRecord:
+		return nameToBytes(rr.DomainName())
+	case SRVRecord:
+		w := NewWriter()
+		w.WriteUint16(uint16(rr.Priority()))
+		w.WriteUint16(uint16(rr.Weight()))
+		w.WriteUint16(uint16(rr.Port()))
+		w.WriteBytes(nameToBytes(rr.Target()))
+		return w.Bytes()
+	}
+	return r.Data()
+}
+
+// CompareRecords compares the specified records in the canonical order, and returns -1, 0 or +1.
+// RFC 6762: 8.2. Simultaneous Probe Tiebreaking
+// The records are compared by the class excluding the cache-flush bit, the type,
+// and then the raw binary content of the uncompressed record data.
+func CompareRecords(r1, r2 Record) int {
+	if c := cmp.Compare(r1.Class()&classMask, r2.Class()&classMask); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(r1.Type(), r2.Type()); c != 0 {
+		return c
+	}
+	return bytes.Compare(canonicalData(r1), canonicalData(r2))
+}
+
 // EqualContent returns true if the record contents are equal. otherwise false.
 func EqualContent(r1, r2 Record) bool {
 	if r1.Type() != r2.Type() {
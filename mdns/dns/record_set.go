@@ -15,6 +15,7 @@
 package dns
 
 import (
+	"cmp"
 	"fmt"
 	"regexp"
 	"slices"
@@ -192,6 +193,29 @@ func (records RecordSet) Equal(other RecordSet) bool {
 	return true
 }
 
+// Sort returns a copy of the record set sorted in the canonical order.
+// RFC 6762: 8.2. Simultaneous Probe Tiebreaking.
+func (records RecordSet) Sort() RecordSet {
+	sortedRecords := slices.Clone(records)
+	slices.SortFunc(sortedRecords, CompareRecords)
+	return sortedRecords
+}
+
+// Compare compares the record sets lexicographically in the canonical order, and returns -1, 0 or +1.
+// RFC 6762: 8.2. Simultaneous Probe Tiebreaking
+// The sorted records are compared pairwise, and if either set runs out of records before any difference is found,
+// the set with records remaining is deemed to be lexicographically later.
+func (records RecordSet) Compare(other RecordSet) int {
+	sortedRecords := records.Sort()
+	otherRecords := other.Sort()
+	for n := range min(len(sortedRecords), len(otherRecords)) {
+		if c := CompareRecords(sortedRecords[n], otherRecords[n]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(sortedRecords), len(otherRecords))
+}
+
 // String returns the string representation.
 func (records RecordSet) String() string {
 	type record []string
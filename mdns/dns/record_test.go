@@ -15,8 +15,10 @@
 package dns
 
 import (
+	"bytes"
 	"fmt"
 	"net"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -66,6 +68,7 @@ func TestResourceRecord(t *testing.T) {
 	t.Run("SRV", func(t *testing.T) {
 		tests := []struct {
 			query            []byte
+			compression      []byte
 			expectedTTL      uint
 			expectedPriority uint
 			expectedWeight   uint
@@ -74,16 +77,28 @@ func TestResourceRecord(t *testing.T) {
 		}{
 			{
 				query:            []byte{0x00, 0x00, 0x21, 0x80, 0x01, 0x00, 0x00, 0x00, 0x78, 0x00, 0x1f, 0x00, 0x01, 0x00, 0x02, 0x1f, 0x49, 0x16, 0x66, 0x75, 0x63, 0x68, 0x73, 0x69, 0x61, 0x2d, 0x37, 0x63, 0x64, 0x39, 0x2d, 0x35, 0x63, 0x34, 0x39, 0x2d, 0x65, 0x30, 0x61, 0x37, 0xc0, 0x1d},
+				compression:      append(make([]byte, 0x1d), 0x05, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x00),
 				expectedTTL:      120,
 				expectedPriority: 1,
 				expectedWeight:   2,
 				expectedPort:     8009,
-				expectedTarget:   "fuchsia-7cd9-5c49-e0a7",
+				expectedTarget:   "fuchsia-7cd9-5c49-e0a7.local",
+			},
+			{
+				query:            []byte{0x00, 0x00, 0x21, 0x80, 0x01, 0x00, 0x00, 0x00, 0x78, 0x00, 0x24, 0x00, 0x01, 0x00, 0x02, 0x1f, 0x49, 0x16, 0x66, 0x75, 0x63, 0x68, 0x73, 0x69, 0x61, 0x2d, 0x37, 0x63, 0x64, 0x39, 0x2d, 0x35, 0x63, 0x34, 0x39, 0x2d, 0x65, 0x30, 0x61, 0x37, 0x05, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x00},
+				compression:      nil,
+				expectedTTL:      120,
+				expectedPriority: 1,
+				expectedWeight:   2,
+				expectedPort:     8009,
+				expectedTarget:   "fuchsia-7cd9-5c49-e0a7.local",
 			},
 		}
 		for _, test := range tests {
 			t.Run(fmt.Sprintf("%d:%d:%d", test.expectedPriority, test.expectedWeight, test.expectedPort), func(t *testing.T) {
-				q, err := NewResourceRecordWithReader(NewReaderWithBytes(test.query))
+				reader := NewReaderWithBytes(test.query)
+				reader.SetCompressionBytes(test.compression)
+				q, err := NewResourceRecordWithReader(reader)
 				if err != nil {
 					t.Error(err)
 				}
@@ -225,6 +240,48 @@ func TestResourceRecord(t *testing.T) {
 		}
 	})
 
+	t.Run("NSEC", func(t *testing.T) {
+		tests := []struct {
+			query              []byte
+			expectedTTL        uint
+			expectedDomainName string
+			expectedTypes      []Type
+		}{
+			{
+				query:              []byte{0x00, 0x00, 0x2f, 0x80, 0x01, 0x00, 0x00, 0x00, 0x78, 0x00, 0x12, 0x04, 0x74, 0x65, 0x73, 0x74, 0x05, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x00, 0x00, 0x04, 0x40, 0x00, 0x00, 0x08},
+				expectedTTL:        120,
+				expectedDomainName: "test.local",
+				expectedTypes:      []Type{A, AAAA},
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.expectedDomainName, func(t *testing.T) {
+				q, err := NewResourceRecordWithReader(NewReaderWithBytes(test.query))
+				if err != nil {
+					t.Error(err)
+				}
+				nsec, ok := q.(NSECRecord)
+				if !ok {
+					t.Errorf("%v", nsec)
+					return
+				}
+				// Checks each field
+				if nsec.TTL() != test.expectedTTL {
+					t.Errorf("TTL: %d != %d", nsec.TTL(), test.expectedTTL)
+				}
+				if nsec.NextDomainName() != test.expectedDomainName {
+					t.Errorf("next domain name: %s != %s", nsec.NextDomainName(), test.expectedDomainName)
+				}
+				if !slices.Equal(nsec.Types(), test.expectedTypes) {
+					t.Errorf("types: %v != %v", nsec.Types(), test.expectedTypes)
+				}
+				if nsec.HasType(TXT) {
+					t.Errorf("types: %v has %s", nsec.Types(), TXT.String())
+				}
+			})
+		}
+	})
+
 	t.Run("OPT", func(t *testing.T) {
 		tests := []struct {
 			query            []byte
@@ -257,3 +314,105 @@ func TestResourceRecord(t *testing.T) {
 		}
 	})
 }
+
+func TestResourceRecordBuilder(t *testing.T) {
+	ptr := NewPTRRecord().SetDomainName("Test._http._tcp.local")
+	ptr.SetName("_http._tcp.local")
+	srv := NewSRVRecord().SetPort(8080).SetTarget("test.local")
+	srv.SetName("Test._http._tcp.local")
+	srv.SetCacheFlush(true)
+	txt := NewTXTRecord().SetStrings([]string{"path=/"})
+	txt.SetName("Test._http._tcp.local")
+	a := NewARecordWithAddress(net.IPv4(192, 0, 2, 1))
+	a.SetName("test.local")
+	aaaa := NewAAAARecord().SetAddress(net.ParseIP("2001:db8::1"))
+	aaaa.SetName("test.local")
+	nsec := NewNSECRecord().SetNextDomainName("Test._http._tcp.local").SetTypes(TXT, SRV, Type(0x0101))
+	nsec.SetName("Test._http._tcp.local")
+	nsec.SetCacheFlush(true)
+
+	records := []Record{ptr, srv, txt, a, aaaa, nsec}
+	for _, record := range records {
+		t.Run(record.Type().String(), func(t *testing.T) {
+			recordBytes, err := record.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsedRecord, err := NewResourceRecordWithReader(NewReaderWithBytes(recordBytes))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !parsedRecord.Equal(record) {
+				t.Errorf("%s != %s", parsedRecord.Content(), record.Content())
+			}
+			if parsedRecord.Class() != IN {
+				t.Errorf("%2X != %2X", parsedRecord.Class(), IN)
+			}
+			if parsedRecord.CacheFlush() != record.CacheFlush() {
+				t.Errorf("%t != %t", parsedRecord.CacheFlush(), record.CacheFlush())
+			}
+		})
+	}
+}
+
+func TestTXTRecordData(t *testing.T) {
+	// RFC 6763: 6.1. General Format Rules for DNS TXT Records
+	tests := []struct {
+		strs     []string
+		expected []byte
+	}{
+		{nil, []byte{0x00}},
+		{[]string{"path=/"}, []byte{0x06, 0x70, 0x61, 0x74, 0x68, 0x3d, 0x2f}},
+		{[]string{"a=1", "b=2"}, []byte{0x03, 0x61, 0x3d, 0x31, 0x03, 0x62, 0x3d, 0x32}},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.strs, ","), func(t *testing.T) {
+			txt := NewTXTRecord().SetStrings(test.strs)
+			if !bytes.Equal(txt.Data(), test.expected) {
+				t.Errorf("%v != %v", txt.Data(), test.expected)
+			}
+			attrs, err := txt.Attributes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(attrs) != len(test.strs) {
+				t.Errorf("%d != %d", len(attrs), len(test.strs))
+			}
+		})
+	}
+}
+
+func TestRecordSetCompare(t *testing.T) {
+	newA := func(ip string) Record {
+		a := NewARecordWithAddress(net.ParseIP(ip))
+		a.SetName("test.local")
+		a.SetCacheFlush(true)
+		return a
+	}
+	newSRV := func(port uint) Record {
+		srv := NewSRVRecord().SetPort(port).SetTarget("test.local")
+		srv.SetName("Test._http._tcp.local")
+		return srv
+	}
+
+	// RFC 6762: 8.2. Simultaneous Probe Tiebreaking
+	tests := []struct {
+		records  RecordSet
+		other    RecordSet
+		expected int
+	}{
+		{RecordSet{newA("169.254.200.50")}, RecordSet{newA("169.254.99.200")}, 1},
+		{RecordSet{newA("169.254.99.200")}, RecordSet{newA("169.254.200.50")}, -1},
+		{RecordSet{newA("169.254.99.200")}, RecordSet{newA("169.254.99.200")}, 0},
+		{RecordSet{newSRV(80)}, RecordSet{newA("169.254.99.200")}, 1},
+		{RecordSet{newSRV(80)}, RecordSet{newSRV(8080)}, -1},
+		{RecordSet{newA("169.254.200.50"), newA("169.254.99.200")}, RecordSet{newA("169.254.99.200")}, 1},
+		{RecordSet{newA("169.254.99.200"), newA("169.254.200.50")}, RecordSet{newA("169.254.200.50"), newA("169.254.99.200")}, 0},
+	}
+
+	for n, test := range tests {
+		if c := test.records.Compare(test.other); c != test.expected {
+			t.Errorf("[%d] %d != %d", n, c, test.expected)
+		}
+	}
+}
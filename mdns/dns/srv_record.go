@@ -25,6 +25,14 @@ type SRVRecord interface {
 	Proto() string
 	// Name returns the domain name.
 	Name() string
+	// SetPriority sets the priority of the target host.
+	SetPriority(priority uint) SRVRecord
+	// SetWeight sets a relative weight for records with the same priority.
+	SetWeight(weight uint) SRVRecord
+	// SetPort sets the port on this target host of this service.
+	SetPort(port uint) SRVRecord
+	// SetTarget sets the canonical hostname of the machine providing the service.
+	SetTarget(target string) SRVRecord
 	// Priority returns the priority of the target host.
 	Priority() uint
 	// Weight returns a relative weight for records with the same priority.
@@ -35,7 +35,7 @@ type srvRecord struct {
 // NewSRVRecord returns a new SRV record instance.
 func NewSRVRecord() SRVRecord {
 	return &srvRecord{
-		record:   newRecord(),
+		record:   newRecord(withRecordType(SRV), withRecordClass(IN)),
 		service:  "",
 		proto:    "",
 		priority: 0,
@@ -103,7 +103,10 @@ func (srv *srvRecord) parseResourceRecord() error {
 		return err
 	}
 
-	srv.target, err = reader.ReadString()
+	// RFC 2782: The target is a domain name, and responders may compress it
+	// against the message (RFC 6762: 18.14. Name Compression).
+	reader.SetCompressionBytes(srv.CompressionBytes())
+	srv.target, err = reader.ReadName()
 	if err != nil {
 		return err
 	}
@@ -111,6 +114,53 @@ func (srv *srvRecord) parseResourceRecord() error {
 	return nil
 }
 
+// updateData updates the record data with the current fields.
+func (srv *srvRecord) updateData() {
+	w := NewWriter()
+	w.WriteUint16(srv.priority)
+	w.WriteUint16(srv.weight)
+	w.WriteUint16(srv.port)
+	w.WriteBytes(nameToBytes(srv.target))
+	srv.data = w.Bytes()
+}
+
+// SetName sets the specified name, and updates the service and protocol names.
+func (srv *srvRecord) SetName(name string) Record {
+	srv.record.SetName(name)
+	srv.service = ""
+	srv.proto = ""
+	srv.parseName()
+	return srv
+}
+
+// SetPriority sets the specified priority.
+func (srv *srvRecord) SetPriority(priority uint) SRVRecord {
+	srv.priority = uint16(priority)
+	srv.updateData()
+	return srv
+}
+
+// SetWeight sets the specified weight.
+func (srv *srvRecord) SetWeight(weight uint) SRVRecord {
+	srv.weight = uint16(weight)
+	srv.updateData()
+	return srv
+}
+
+// SetPort sets the specified port.
+func (srv *srvRecord) SetPort(port uint) SRVRecord {
+	srv.port = uint16(port)
+	srv.updateData()
+	return srv
+}
+
+// SetTarget sets the specified target.
+func (srv *srvRecord) SetTarget(target string) SRVRecord {
+	srv.target = target
+	srv.updateData()
+	return srv
+}
+
 // Service returns the service name.
 func (srv *srvRecord) Service() string {
 	return srv.service
@@ -14,6 +14,11 @@
 
 package dns
 
+// txtToBytes returns the TXT record data of the specified strings.
+// RFC 6763: 6.1. General Format Rules for DNS TXT Records
+// The strings are not terminated, because a trailing zero byte is decoded by other
+// implementations as an extra empty string. An empty TXT record containing zero
+// strings is not allowed, so a single zero byte is used instead.
 func txtToBytes(attrs []string) []byte {
 	capacity := 1
 	for _, attr := range attrs {
@@ -25,6 +30,8 @@ func txtToBytes(attrs []string) []byte {
 		bytes = append(bytes, attrLen)
 		bytes = append(bytes, []byte(attr)...)
 	}
-	bytes = append(bytes, 0x00)
+	if len(attrs) == 0 {
+		bytes = append(bytes, 0x00)
+	}
 	return bytes
 }
@@ -16,6 +16,10 @@ package dns
 
 type TXTRecord interface {
 	Record
+	// SetStrings sets the resource text strings.
+	SetStrings(strs []string) TXTRecord
+	// SetAttributes sets the resource attributes.
+	SetAttributes(attrs Attributes) TXTRecord
 	// Strings returns the resource text strings.
 	Strings() []string
 	// Attributes returns the resource attributes.
@@ -27,7 +27,7 @@ type txtRecord struct {
 // NewTXTRecord returns a new TXT record instance.
 func NewTXTRecord() TXTRecord {
 	return &txtRecord{
-		record: newRecord(),
+		record: newRecord(withRecordType(TXT), withRecordClass(IN)),
 		strs:   []string{},
 	}
 }
@@ -51,6 +51,22 @@ func (txt *txtRecord) parseResourceRecord() error {
 	return err
 }
 
+// SetStrings sets the resource attribute strings.
+func (txt *txtRecord) SetStrings(strs []string) TXTRecord {
+	txt.strs = strs
+	txt.data = txtToBytes(strs)
+	return txt
+}
+
+// SetAttributes sets the resource attributes.
+func (txt *txtRecord) SetAttributes(attrs Attributes) TXTRecord {
+	strs := make([]string, 0, len(attrs))
+	for _, attr := range attrs {
+		strs = append(strs, attr.String())
+	}
+	return txt.SetStrings(strs)
+}
+
 // Strings returns the resource attribute strings.
 func (txt *txtRecord) Strings() []string {
 	return txt.strs
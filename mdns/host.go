@@ -0,0 +1,321 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-logger/log"
+	"github.com/cybergarage/go-mdns/mdns/dns"
+	"github.com/cybergarage/go-mdns/mdns/transport"
+)
+
+const (
+	// DefaultHostLabel is the host label used when the host name of the node is not available.
+	DefaultHostLabel = "mdns"
+	// ReverseIPv4Domain is the domain of the reverse mapping records for IPv4 addresses.
+	ReverseIPv4Domain = "in-addr.arpa"
+	// ReverseIPv6Domain is the domain of the reverse mapping records for IPv6 addresses.
+	ReverseIPv6Domain = "ip6.arpa"
+	// HostAddressRefreshInterval is the interval to reload the interface addresses of the host records.
+	HostAddressRefreshInterval = 10 * time.Second
+)
+
+// sanitizeHostLabel returns a host label which consists of only letters, digits and hyphens from the specified name.
+// RFC 6762: 16. Multicast DNS Character Set.
+func sanitizeHostLabel(name string) string {
+	label, _, _ := strings.Cut(name, dns.LabelSeparator)
+	label = strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '-':
+			return r
+		}
+		return '-'
+	}, label)
+	label = strings.Trim(label, "-")
+	if 63 < len(label) {
+		label = strings.TrimRight(label[:63], "-")
+	}
+	return label
+}
+
+// defaultHostName returns the host name of this node in the local domain, such as "myhost.local".
+func defaultHostName() string {
+	name, _ := os.Hostname()
+	label := sanitizeHostLabel(name)
+	if len(label) == 0 {
+		label = DefaultHostLabel
+	}
+	return dns.NewNameWithStrings(label, LocalDomain)
+}
+
+// hostNameWithDomain returns the specified host name with the local domain if the name has no domain.
+func hostNameWithDomain(host string) string {
+	if strings.Contains(host, dns.LabelSeparator) {
+		return host
+	}
+	return dns.NewNameWithStrings(host, LocalDomain)
+}
+
+// reverseName returns the reverse mapping name of the specified address,
+// such as "10.2.0.192.in-addr.arpa" or "0.1.0.0.[...].8.b.d.0.1.0.0.2.ip6.arpa".
+func reverseName(ip net.IP) string {
+	labels := []string{}
+	if ip4 := ip.To4(); ip4 != nil {
+		for n := len(ip4) - 1; 0 <= n; n-- {
+			labels = append(labels, fmt.Sprintf("%d", ip4[n]))
+		}
+		return dns.NewNameWithStrings(append(labels, ReverseIPv4Domain)...)
+	}
+	ip6 := ip.To16()
+	for n := len(ip6) - 1; 0 <= n; n-- {
+		labels = append(labels, fmt.Sprintf("%x", ip6[n]&0x0F), fmt.Sprintf("%x", ip6[n]>>4))
+	}
+	return dns.NewNameWithStrings(append(labels, ReverseIPv6Domain)...)
+}
+
+// newHostRecords returns the A and AAAA records of the specified host, and the reverse mapping PTR records of the addresses.
+// RFC 6762: 6. Responding
+// RFC 6762: 10. Resource Record TTL Values and Cache Coherency.
+func newHostRecords(host string, addrs []net.IP) ResourceRecordSet {
+	records := newAddressRecords(host, addrs)
+	for _, addr := range addrs {
+		ptr := dns.NewPTRRecord().SetDomainName(host)
+		ptr.SetName(reverseName(addr))
+		ptr.SetTTL(DefaultHostRecordTTL)
+		ptr.SetCacheFlush(true)
+		records = append(records, ptr)
+	}
+	return records
+}
+
+// interfaceAddresses returns the addresses of the specified interfaces.
+func interfaceAddresses(ifis []*net.Interface) []net.IP {
+	addrs := []net.IP{}
+	for _, ifi := range ifis {
+		ifaddrs, err := transport.GetInterfaceAddresses(ifi)
+		if err != nil {
+			continue
+		}
+		for _, ifaddr := range ifaddrs {
+			if addr := net.ParseIP(ifaddr); addr != nil {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+// hostAddressCache represents the addresses of the available interfaces which are reloaded periodically,
+// so that the interfaces are not enumerated for each received message.
+type hostAddressCache struct {
+	sync.Mutex
+	ifaddrs          [][]net.IP
+	loaded           time.Time
+	now              func() time.Time
+	addressesChanged func(oldAddrs []net.IP)
+}
+
+// newHostAddressCache returns a new host address cache.
+func newHostAddressCache() *hostAddressCache {
+	return &hostAddressCache{
+		Mutex:            sync.Mutex{},
+		ifaddrs:          nil,
+		loaded:           time.Time{},
+		now:              time.Now,
+		addressesChanged: nil,
+	}
+}
+
+// reloadHostAddresses discards the cached addresses, and the addresses are reloaded on the next lookup.
+func (cache *hostAddressCache) reloadHostAddresses() {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.ifaddrs = nil
+}
+
+// interfaceAddressSets returns the addresses of each available interface,
+// and reloads them if they have been loaded more than HostAddressRefreshInterval ago.
+// The change of the reloaded addresses is notified to the addressesChanged function with the previous addresses.
+func (cache *hostAddressCache) interfaceAddressSets() [][]net.IP {
+	ifaddrs, oldIfaddrs := cache.loadInterfaceAddressSets()
+	if oldIfaddrs == nil || cache.addressesChanged == nil {
+		return ifaddrs
+	}
+	isEqualAddrs := func(addrs []net.IP, otherAddrs []net.IP) bool {
+		return slices.EqualFunc(addrs, otherAddrs, net.IP.Equal)
+	}
+	if !slices.EqualFunc(ifaddrs, oldIfaddrs, isEqualAddrs) {
+		cache.addressesChanged(slices.Concat(oldIfaddrs...))
+	}
+	return ifaddrs
+}
+
+// loadInterfaceAddressSets returns the addresses of each available interface, and the previous addresses
+// if the addresses are reloaded after HostAddressRefreshInterval, otherwise nil.
+func (cache *hostAddressCache) loadInterfaceAddressSets() ([][]net.IP, [][]net.IP) {
+	cache.Lock()
+	defer cache.Unlock()
+	now := cache.now()
+	if cache.ifaddrs != nil && now.Sub(cache.loaded) < HostAddressRefreshInterval {
+		return cache.ifaddrs, nil
+	}
+	ifis, err := transport.GetAvailableInterfaces()
+	if err != nil {
+		return [][]net.IP{}, nil
+	}
+	oldIfaddrs := cache.ifaddrs
+	cache.ifaddrs = [][]net.IP{}
+	for _, ifi := range ifis {
+		cache.ifaddrs = append(cache.ifaddrs, interfaceAddresses([]*net.Interface{ifi}))
+	}
+	cache.loaded = now
+	return cache.ifaddrs, oldIfaddrs
+}
+
+// hostAddresses returns the addresses of the interface which has the specified local address,
+// or the addresses of all available interfaces if the address is nil or not found.
+// RFC 6762: 14. Considerations for Multiple Interfaces
+// When a Multicast DNS responder sends a Multicast DNS response message containing its own address records,
+// it MUST include all addresses that are valid on the interface on which it is sending the message,
+// and MUST NOT include addresses that are not valid on that interface.
+func (cache *hostAddressCache) hostAddresses(ifaddr net.IP) []net.IP {
+	ifaddrs := cache.interfaceAddressSets()
+	if ifaddr != nil {
+		for _, addrs := range ifaddrs {
+			if slices.ContainsFunc(addrs, ifaddr.Equal) {
+				return slices.Clone(addrs)
+			}
+		}
+	}
+	return slices.Concat(ifaddrs...)
+}
+
+// SetHost sets the host name of this server, such as "myhost" or "myhost.local".
+// The host name is the target of the registered services which have no host names.
+func (server *Server) SetHost(host string) {
+	server.Lock()
+	defer server.Unlock()
+	server.host = hostNameWithDomain(host)
+}
+
+// Host returns the host name of this server.
+func (server *Server) Host() string {
+	server.Lock()
+	defer server.Unlock()
+	return server.host
+}
+
+// hostRecords returns the host records on the interface which has the specified local address,
+// or the host records of all available interfaces if the address is nil.
+func (server *Server) hostRecords(ifaddr net.IP) ResourceRecordSet {
+	return newHostRecords(server.Host(), server.hostAddresses(ifaddr))
+}
+
+// hostServices returns the registered services whose target is the host name of this server.
+func (server *Server) hostServices() []Service {
+	server.Lock()
+	defer server.Unlock()
+	return slices.DeleteFunc(slices.Clone(server.Services()), func(service Service) bool {
+		return len(service.Host()) != 0
+	})
+}
+
+// announceHost announces the host records which are valid on each interface in the background.
+// The running announcements of the previous host records are canceled.
+// RFC 6762: 8.3. Announcing
+// RFC 6762: 14. Considerations for Multiple Interfaces
+func (server *Server) announceHost() {
+	server.cancelAnnouncers(nil)
+	server.announce(nil, server.hostRecords, server.IsRunning)
+}
+
+// sendHostGoodbye multicasts the host records which are valid on each interface with TTL zero,
+// and returns the sent records.
+// RFC 6762: 10.1. Goodbye Packets
+// RFC 6762: 14. Considerations for Multiple Interfaces
+func (server *Server) sendHostGoodbye() (ResourceRecordSet, error) {
+	if !server.IsRunning() {
+		return ResourceRecordSet{}, nil
+	}
+	return server.multicastInterfaceRecords(func(ifaddr net.IP) ResourceRecordSet {
+		records := server.hostRecords(ifaddr)
+		for _, record := range records {
+			record.SetTTL(0)
+		}
+		return records
+	})
+}
+
+// hostAddressesChanged sends goodbye packets for the removed addresses, and announces the host records again
+// when the reloaded addresses of the interfaces are changed.
+// RFC 6762: 8.4. Updating
+func (server *Server) hostAddressesChanged(oldAddrs []net.IP) {
+	if !server.IsRunning() {
+		return
+	}
+	addrs := server.hostAddresses(nil)
+	removedAddrs := slices.DeleteFunc(slices.Clone(oldAddrs), func(addr net.IP) bool {
+		return slices.ContainsFunc(addrs, addr.Equal)
+	})
+	if err := server.sendGoodbye(newHostRecords(server.Host(), removedAddrs)); err != nil {
+		log.Error(err)
+	}
+	server.announceHost()
+}
+
+// claimHost probes the host name of this server, and announces the host records.
+// If any other host already uses the host name, the host name is renamed and probed again until the name is claimed.
+// The services whose target is the host name are notified of the rename, and announced again with the new target.
+// RFC 6762: 8.1. Probing
+// RFC 6762: 9. Conflict Resolution.
+func (server *Server) claimHost() error {
+	conflicts := []time.Time{}
+	renamed := false
+	for server.IsRunning() {
+		// The reverse mapping records are not probed because the addresses are not named by this host.
+		records := server.hostRecords(nil)
+		conflict, err := server.probe(records.LookupRecordSetByName(server.Host()))
+		if err != nil {
+			return err
+		}
+		if conflict == nil {
+			server.announceHost()
+			if renamed {
+				for _, service := range server.hostServices() {
+					server.notifyRegistrationState(service, RegistrationRegistered)
+					server.cancelAnnouncers(service)
+					server.announceService(service)
+				}
+			}
+			return nil
+		}
+
+		label, domain, _ := strings.Cut(server.Host(), dns.LabelSeparator)
+		server.SetHost(dns.NewNameWithStrings(nextName(label, hostNumberRegex, "%s-%d"), domain))
+		renamed = true
+		for _, service := range server.hostServices() {
+			server.notifyRegistrationState(service, RegistrationRenamed)
+		}
+		conflicts = server.throttleConflicts(conflicts)
+	}
+	return nil
+}
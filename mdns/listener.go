@@ -16,3 +16,6 @@ package mdns
 
 // MessageHandler is a message handler function type.
 type MessageHandler func(Message)
+
+// RegistrationHandler is a registration state handler function type.
+type RegistrationHandler func(Service, RegistrationState)
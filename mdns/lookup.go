@@ -0,0 +1,128 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+// lookupAnswers sends the query of the specified questions, and returns the cached answers as soon as any unique answer is cached,
+// or the cached answers when the context is done.
+// RFC 6762: 5.1. One-Shot Multicast DNS Queries
+// RFC 6762: 5.2. Continuous Multicast DNS Querying
+func (client *clientImpl) lookupAnswers(ctx context.Context, questions []dns.Question) (ResourceRecordSet, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultQueryTimeout)
+		defer cancel()
+	}
+
+	cachedAnswers := func() ResourceRecordSet {
+		answers := ResourceRecordSet{}
+		for _, q := range questions {
+			answers = append(answers, client.cache.LookupRecords(q.Name(), q.Type(), q.Class())...)
+		}
+		return answers
+	}
+
+	interval := BrowseInitialInterval
+	queryTimer := time.NewTimer(0)
+	defer queryTimer.Stop()
+	for {
+		updates := client.cache.updates()
+		answers := cachedAnswers()
+		if 0 < len(uniqueRecords(answers)) {
+			return answers, nil
+		}
+		select {
+		case <-ctx.Done():
+			return answers, nil
+		case <-updates:
+		case <-queryTimer.C:
+			msg := dns.NewRequestMessage(
+				dns.WithMessageQuestions(questions...),
+				dns.WithMessageAnswers(client.cache.knownAnswers(questions)...),
+			)
+			if err := client.sendQueryMessages([]Message{msg}); err != nil {
+				return nil, err
+			}
+			queryTimer.Reset(interval)
+			interval = min(interval*2, BrowseMaxInterval)
+		}
+	}
+}
+
+// LookupHost sends the A and AAAA queries of the specified host name, such as "myhost.local",
+// and returns the addresses of the host as soon as any unique answer is received.
+// RFC 6762: 5.1. One-Shot Multicast DNS Queries
+func (client *clientImpl) LookupHost(ctx context.Context, host string) ([]netip.Addr, error) {
+	answers, err := client.lookupAnswers(ctx, newQuestions(host, dns.A, dns.AAAA))
+	if err != nil {
+		return nil, err
+	}
+	addrs := []netip.Addr{}
+	for _, answer := range answers {
+		var ip net.IP
+		switch record := answer.(type) {
+		case dns.ARecord:
+			ip = record.Address()
+		case dns.AAAARecord:
+			ip = record.Address()
+		default:
+			continue
+		}
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok {
+			continue
+		}
+		addr = addr.Unmap()
+		if slices.Contains(addrs, addr) {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("host (%s) is %w", host, ErrNotFound)
+	}
+	return addrs, nil
+}
+
+// LookupAddr sends the reverse mapping PTR query of the specified address under "in-addr.arpa" or "ip6.arpa",
+// and returns the host names of the address as soon as any unique answer is received.
+// RFC 6762: 4. Reverse Address Mapping
+func (client *clientImpl) LookupAddr(ctx context.Context, addr netip.Addr) ([]string, error) {
+	answers, err := client.lookupAnswers(ctx, newQuestions(reverseName(net.IP(addr.AsSlice())), dns.PTR))
+	if err != nil {
+		return nil, err
+	}
+	hosts := []string{}
+	for _, ptr := range answers.LookupPTRRecordSet() {
+		if slices.Contains(hosts, ptr.DomainName()) {
+			continue
+		}
+		hosts = append(hosts, ptr.DomainName())
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("address (%s) is %w", addr, ErrNotFound)
+	}
+	return hosts, nil
+}
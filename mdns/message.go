@@ -16,17 +16,64 @@ package mdns
 
 import (
 	"github.com/cybergarage/go-mdns/mdns/dns"
+	"github.com/cybergarage/go-mdns/mdns/transport"
+)
+
+// RFC 6762 - Multicast DNS.
+const (
+	// MaxMessageSize is the maximum size of the query messages to fit in the Ethernet MTU without the IPv6 and UDP headers.
+	// 17. Multicast DNS Message Size
+	MaxMessageSize = transport.MaxPacketSize - 40 - 8
 )
 
 // Message represents a protocol message.
 type Message = dns.Message
 
-// NewRequestWithQuery returns a nil message instance.
+// NewRequestWithQuery returns a request message instance with the questions of the specified query.
 func NewRequestWithQuery(query Query) Message {
-	question := dns.NewQuestion(
-		dns.WithQuestionName(query.String()),
-		dns.WithQuestionType(dns.ANY),
-		dns.WithQuestionClass(QU|dns.IN),
-	)
-	return dns.NewRequestMessage(dns.WithMessageQuestions(question))
+	return dns.NewRequestMessage(dns.WithMessageQuestions(query.Questions()...))
+}
+
+// NewRequestsWithQuery returns the request messages for the specified query with the known answers of the query.
+// If the known answers do not fit in a single message, they are split into the following messages,
+// and the TC bit is set on all messages except the last one.
+// RFC 6762: 7.2. Multipacket Known-Answer Suppression
+func NewRequestsWithQuery(query Query) []Message {
+	return newRequestsWithKnownAnswers(query.Questions(), query.KnownAnswers())
+}
+
+// newRequestsWithKnownAnswers returns the request messages for the specified questions with the specified known answers.
+// RFC 6762: 7.1. Known-Answer Suppression
+// RFC 6762: 7.2. Multipacket Known-Answer Suppression
+func newRequestsWithKnownAnswers(questions dns.Questions, knownAnswers ResourceRecordSet) []Message {
+	reqMsg := dns.NewRequestMessage(dns.WithMessageQuestions(questions...))
+	msgSize := len(reqMsg.Bytes())
+	msgAnswers := []ResourceRecordSet{{}}
+	for _, answer := range knownAnswers {
+		answerBytes, err := answer.ResponseBytes()
+		if err != nil {
+			continue
+		}
+		n := len(msgAnswers) - 1
+		if MaxMessageSize < msgSize+len(answerBytes) && 0 < len(msgAnswers[n]) {
+			msgAnswers = append(msgAnswers, ResourceRecordSet{})
+			n++
+			msgSize = len(dns.NewRequestHeader().Bytes())
+		}
+		msgAnswers[n] = append(msgAnswers[n], answer)
+		msgSize += len(answerBytes)
+	}
+
+	reqMsgs := []Message{}
+	for n, answers := range msgAnswers {
+		opts := []dns.MessageOption{
+			dns.WithMessageAnswers(answers...),
+			dns.WithMessageTC(n < len(msgAnswers)-1),
+		}
+		if n == 0 {
+			opts = append(opts, dns.WithMessageQuestions(reqMsg.Questions()...))
+		}
+		reqMsgs = append(reqMsgs, dns.NewRequestMessage(opts...))
+	}
+	return reqMsgs
 }
@@ -0,0 +1,162 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// RFC 6762 - Multicast DNS.
+const (
+	// MulticastInterval is the minimum interval to multicast the same record on the same interface.
+	// 6. Responding
+	MulticastInterval = time.Second
+	// ProbeDefenseInterval is the minimum interval to multicast the same record on the same interface to defend against probes.
+	// 6. Responding
+	ProbeDefenseInterval = 250 * time.Millisecond
+)
+
+// multicastHistory represents the last multicast time and the TTL of a record.
+type multicastHistory struct {
+	time time.Time
+	ttl  time.Duration
+}
+
+// multicastLimiter represents a rate limiter which tracks the last multicast time of each record on each interface.
+type multicastLimiter struct {
+	sync.Mutex
+	lastMulticasts map[string]multicastHistory
+	now            func() time.Time
+}
+
+// newMulticastLimiter returns a new multicast limiter.
+func newMulticastLimiter() *multicastLimiter {
+	return &multicastLimiter{
+		Mutex:          sync.Mutex{},
+		lastMulticasts: map[string]multicastHistory{},
+		now:            time.Now,
+	}
+}
+
+// multicastRecordKey returns the key of the specified record on the specified interface.
+// The empty interface key represents all interfaces.
+func multicastRecordKey(ifkey string, record ResourceRecord) string {
+	return strings.Join([]string{ifkey, strings.ToLower(record.Name()), record.Type().String(), record.Content()}, "/")
+}
+
+// pruneMulticasts removes the multicast histories which are no longer needed to limit the multicasts
+// nor to decide the unicast responses. The limiter must be locked by the caller.
+func (limiter *multicastLimiter) pruneMulticasts(now time.Time) {
+	for key, last := range limiter.lastMulticasts {
+		if max(MulticastInterval, last.ttl/4) <= now.Sub(last.time) {
+			delete(limiter.lastMulticasts, key)
+		}
+	}
+}
+
+// multicastRecords records that the specified records are multicast on the specified interface now.
+// The empty interface key represents all interfaces, such as announcements.
+func (limiter *multicastLimiter) multicastRecords(ifkey string, records ResourceRecordSet) {
+	limiter.Lock()
+	defer limiter.Unlock()
+	now := limiter.now()
+	limiter.pruneMulticasts(now)
+	for _, record := range records {
+		limiter.lastMulticasts[multicastRecordKey(ifkey, record)] = multicastHistory{
+			time: now,
+			ttl:  time.Duration(record.TTL()) * time.Second,
+		}
+	}
+}
+
+// limitMulticastRecords returns the records which have not been multicast on the specified interface nor on all interfaces,
+// such as announcements, within the specified interval, and updates the last multicast time of the returned records.
+// RFC 6762: 6. Responding
+// A Multicast DNS responder MUST NOT multicast a record on a given interface until at least one second has elapsed
+// since the last time that record was multicast on that particular interface.
+func (limiter *multicastLimiter) limitMulticastRecords(ifkey string, records ResourceRecordSet, interval time.Duration) ResourceRecordSet {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	now := limiter.now()
+	limiter.pruneMulticasts(now)
+
+	isMulticastWithin := func(record ResourceRecord) bool {
+		for _, key := range []string{multicastRecordKey(ifkey, record), multicastRecordKey("", record)} {
+			if last, ok := limiter.lastMulticasts[key]; ok && now.Sub(last.time) < interval {
+				return true
+			}
+		}
+		return false
+	}
+
+	limitedRecords := ResourceRecordSet{}
+	for _, record := range records {
+		if isMulticastWithin(record) {
+			continue
+		}
+		limiter.lastMulticasts[multicastRecordKey(ifkey, record)] = multicastHistory{
+			time: now,
+			ttl:  time.Duration(record.TTL()) * time.Second,
+		}
+		limitedRecords = append(limitedRecords, record)
+	}
+	return limitedRecords
+}
+
+// isMulticastRecently returns true if all the specified records have been multicast on the specified interface
+// or on all interfaces within one quarter of their TTLs, otherwise false.
+// RFC 6762: 5.4. Questions Requesting Unicast Responses
+// If the responder has not multicast that record recently (within one quarter of its TTL),
+// then the responder SHOULD instead multicast the response so as to keep all the peer caches up to date.
+func (limiter *multicastLimiter) isMulticastRecently(ifkey string, records ResourceRecordSet) bool {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	now := limiter.now()
+	limiter.pruneMulticasts(now)
+
+	for _, record := range records {
+		isRecent := false
+		for _, key := range []string{multicastRecordKey(ifkey, record), multicastRecordKey("", record)} {
+			if last, ok := limiter.lastMulticasts[key]; ok && now.Sub(last.time) < last.ttl/4 {
+				isRecent = true
+				break
+			}
+		}
+		if !isRecent {
+			return false
+		}
+	}
+	return true
+}
+
+// isOwnMulticast returns true if the specified record has been multicast by this host on any interface within MulticastInterval,
+// so that the received record is regarded as the own packet looped back rather than a record of another host.
+func (limiter *multicastLimiter) isOwnMulticast(record ResourceRecord) bool {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	now := limiter.now()
+	suffix := multicastRecordKey("", record)
+	for key, last := range limiter.lastMulticasts {
+		if strings.HasSuffix(key, suffix) && now.Sub(last.time) < MulticastInterval {
+			return true
+		}
+	}
+	return false
+}
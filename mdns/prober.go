@@ -0,0 +1,258 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+// RFC 6762 - Multicast DNS.
+const (
+	// ProbeCount is the number of probe queries to send before claiming unique names.
+	// 8.1. Probing
+	ProbeCount = 3
+	// ProbeInterval is the interval between probe queries.
+	// 8.1. Probing
+	ProbeInterval = 250 * time.Millisecond
+	// ProbeDeferTime is the time to wait before probing again when the host loses a simultaneous probe tiebreak.
+	// 8.2. Simultaneous Probe Tiebreaking
+	ProbeDeferTime = time.Second
+)
+
+// prober represents a probing process for the unique records of a registration.
+type prober struct {
+	records    ResourceRecordSet
+	conflictCh chan ResourceRecord
+	deferCh    chan struct{}
+}
+
+// proberSet represents a set of the running probers.
+type proberSet struct {
+	sync.Mutex
+	probers []*prober
+}
+
+// newProber returns a new prober for the specified proposed records.
+func newProber(records ResourceRecordSet) *prober {
+	return &prober{
+		records:    uniqueRecords(records),
+		conflictCh: make(chan ResourceRecord, 1),
+		deferCh:    make(chan struct{}, 1),
+	}
+}
+
+// newProberSet returns a new prober set.
+func newProberSet() *proberSet {
+	return &proberSet{
+		Mutex:   sync.Mutex{},
+		probers: []*prober{},
+	}
+}
+
+// uniqueRecords returns the records which are unique to this host, that is, the records with the cache-flush bit.
+// RFC 6762: 10.2. Announcements to Flush Outdated Cache Entries.
+func uniqueRecords(records ResourceRecordSet) ResourceRecordSet {
+	uniqueRecords := ResourceRecordSet{}
+	for _, record := range records {
+		if record.CacheFlush() {
+			uniqueRecords = append(uniqueRecords, record)
+		}
+	}
+	return uniqueRecords
+}
+
+// names returns the unique names of the proposed records.
+func (p *prober) names() []string {
+	names := []string{}
+	for _, record := range p.records {
+		if slices.ContainsFunc(names, record.IsName) {
+			continue
+		}
+		names = append(names, record.Name())
+	}
+	return names
+}
+
+// probeMessage returns a probe query message which has the proposed records in the authority section.
+// RFC 6762: 8.1. Probing
+// RFC 6762: 8.2. Simultaneous Probe Tiebreaking.
+func (p *prober) probeMessage(unicastResponse bool) Message {
+	cls := dns.IN
+	if unicastResponse {
+		cls |= QU
+	}
+	questions := []dns.Question{}
+	for _, name := range p.names() {
+		q := dns.NewQuestion(
+			dns.WithQuestionName(name),
+			dns.WithQuestionType(dns.ANY),
+			dns.WithQuestionClass(cls),
+		)
+		questions = append(questions, q)
+	}
+	return dns.NewRequestMessage(
+		dns.WithMessageQuestions(questions...),
+		dns.WithMessageNameServers(p.records...),
+	)
+}
+
+// conflictingRecord returns the record in the specified records which conflicts with the specified unique records.
+// A record conflicts when it has the same name, type and class as any unique record, but its data matches none of them.
+// RFC 6762: 9. Conflict Resolution.
+func conflictingRecord(uniqueRecords ResourceRecordSet, records ResourceRecordSet) (ResourceRecord, bool) {
+	for _, record := range records {
+		ownRecords := lookupAnswerRecords(uniqueRecords, dns.NewQuestion(
+			dns.WithQuestionName(record.Name()),
+			dns.WithQuestionType(record.Type()),
+			dns.WithQuestionClass(record.Class()),
+		))
+		if len(ownRecords) == 0 || slices.ContainsFunc(ownRecords, record.Equal) {
+			continue
+		}
+		return record, true
+	}
+	return nil, false
+}
+
+// probeConflictingRecord returns the record in the specified records which conflicts with the specified proposed records.
+// Since a probe asks for all types, a record conflicts when it has the same name and class as any proposed record
+// regardless of its type, but its data matches none of them.
+// RFC 6762: 8.1. Probing.
+func probeConflictingRecord(proposedRecords ResourceRecordSet, records ResourceRecordSet) (ResourceRecord, bool) {
+	for _, record := range records {
+		ownRecords := lookupAnswerRecords(proposedRecords, dns.NewQuestion(
+			dns.WithQuestionName(record.Name()),
+			dns.WithQuestionType(dns.ANY),
+			dns.WithQuestionClass(record.Class()),
+		))
+		if len(ownRecords) == 0 || slices.ContainsFunc(ownRecords, record.Equal) {
+			continue
+		}
+		return record, true
+	}
+	return nil, false
+}
+
+// responseReceived checks the records of the response from another host, and notifies the first conflicting record.
+func (p *prober) responseReceived(records ResourceRecordSet) {
+	record, ok := probeConflictingRecord(p.records, records)
+	if !ok {
+		return
+	}
+	select {
+	case p.conflictCh <- record:
+	default:
+	}
+}
+
+// probeReceived compares the proposed records with the authority records of the probe query from another host,
+// and notifies to defer probing if the proposed records are lexicographically earlier.
+// RFC 6762: 8.2. Simultaneous Probe Tiebreaking.
+func (p *prober) probeReceived(records ResourceRecordSet) {
+	for _, name := range p.names() {
+		otherRecords := records.LookupRecordSetByName(name)
+		if len(otherRecords) == 0 {
+			continue
+		}
+		if 0 <= p.records.LookupRecordSetByName(name).Compare(otherRecords) {
+			continue
+		}
+		select {
+		case p.deferCh <- struct{}{}:
+		default:
+		}
+		return
+	}
+}
+
+// addProber adds the specified prober to receive response messages.
+func (set *proberSet) addProber(p *prober) {
+	set.Lock()
+	defer set.Unlock()
+	set.probers = append(set.probers, p)
+}
+
+// removeProber removes the specified prober.
+func (set *proberSet) removeProber(p *prober) {
+	set.Lock()
+	defer set.Unlock()
+	set.probers = slices.DeleteFunc(set.probers, func(other *prober) bool {
+		return other == p
+	})
+}
+
+// responseReceived passes the records of the response from another host to all running probers.
+func (set *proberSet) responseReceived(records ResourceRecordSet) {
+	set.Lock()
+	defer set.Unlock()
+	for _, p := range set.probers {
+		p.responseReceived(records)
+	}
+}
+
+// probeReceived passes the authority records of the probe query to all running probers.
+func (set *proberSet) probeReceived(records ResourceRecordSet) {
+	set.Lock()
+	defer set.Unlock()
+	for _, p := range set.probers {
+		p.probeReceived(records)
+	}
+}
+
+// probe sends the probe queries for the unique records in the specified records, and returns the conflicting record
+// if any other host already uses the names.
+// RFC 6762: 8.1. Probing
+// RFC 6762: 8.2. Simultaneous Probe Tiebreaking.
+func (server *Server) probe(records ResourceRecordSet) (ResourceRecord, error) {
+	p := newProber(records)
+	if len(p.records) == 0 {
+		return nil, nil
+	}
+
+	server.addProber(p)
+	defer server.removeProber(p)
+
+	// 8.1. Probing
+	// When the host is ready to send its probe, it SHOULD delay the first probe
+	// by a random amount of time uniformly distributed in the range 0-250 ms.
+	wait := time.Duration(rand.Int64N(int64(ProbeInterval)))
+	for n := 0; ; n++ {
+		select {
+		case record := <-p.conflictCh:
+			return record, nil
+		case <-p.deferCh:
+			// 8.2. Simultaneous Probe Tiebreaking
+			// The host that loses MUST defer to the winning host by waiting one second, and then begin probing again.
+			n = -1
+			wait = ProbeDeferTime
+			continue
+		case <-time.After(wait):
+		}
+		// If no conflicting response is received within 250 ms after the third probe, the host may claim the names.
+		if ProbeCount <= n {
+			return nil, nil
+		}
+		// The first probe SHOULD set the unicast-response bit to reduce the multicast traffic.
+		if err := server.AnnounceMessage(p.probeMessage(n == 0)); err != nil {
+			return nil, err
+		}
+		wait = ProbeInterval
+	}
+}
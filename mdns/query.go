@@ -23,6 +23,9 @@ import (
 // Class represents a DNS class.
 type Class = dns.Class
 
+// Type represents a DNS type.
+type Type = dns.Type
+
 // RFC 6762 - Multicast DNS.
 const (
 	// LocalDomain is the local domain name.
@@ -65,8 +68,13 @@ const (
 	DefaultQueryDomain = LocalDomain
 	// DefaultQueryTimeout is the default timeout duration for mDNS queries.
 	DefaultQueryTimeout = time.Duration(5) * time.Second
+	// DefaultQueryType is the default question type for mDNS queries.
+	DefaultQueryType = dns.ANY
 )
 
+// QueryFilter represents a filter which returns true if the specified service should be returned by the query.
+type QueryFilter func(Service) bool
+
 // Query represents a question query.
 type Query interface {
 	// Subtype returns the subtype of the query.
@@ -75,8 +83,22 @@ type Query interface {
 	Service() string
 	// Domain returns the domain name of the query.
 	Domain() string
+	// Types returns the question types of the query.
+	Types() []Type
+	// IsUnicastResponse returns true if the first query message requests unicast responses, otherwise false.
+	IsUnicastResponse() bool
+	// Questions returns the questions of the query for each question type.
+	Questions() dns.Questions
+	// KnownAnswers returns the known answers to include in the query messages.
+	KnownAnswers() ResourceRecordSet
 	// MessageHandler returns the message handler of the query if set.
 	MessageHandler() (MessageHandler, bool)
+	// MaxResults returns the maximum number of the services to return, or zero if unlimited.
+	MaxResults() int
+	// IdleTimeout returns the duration to return after no new services are found, or zero if the query waits until the context is done.
+	IdleTimeout() time.Duration
+	// MatchService returns true if the specified service matches all filters of the query, otherwise false.
+	MatchService(service Service) bool
 	// String returns the string representation of the query.
 	String() string
 }
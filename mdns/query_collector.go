@@ -0,0 +1,126 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+// queryCollector collects the names answered to the questions of a query, and passes the received messages to the message handler of the query.
+// Each query has its own collector, so that the concurrent queries on a client do not affect each other.
+type queryCollector struct {
+	sync.Mutex
+	questions dns.Questions
+	handler   MessageHandler
+	names     []string
+}
+
+// newQueryCollector returns a new collector of the specified questions and message handler.
+func newQueryCollector(questions dns.Questions, handler MessageHandler) *queryCollector {
+	return &queryCollector{
+		Mutex:     sync.Mutex{},
+		questions: questions,
+		handler:   handler,
+		names:     []string{},
+	}
+}
+
+// answerName returns the name of the service which the specified answer represents,
+// that is, the instance name which the PTR answer points to, or the name of the other answer.
+func answerName(answer ResourceRecord) string {
+	if ptr, ok := answer.(dns.PTRRecord); ok {
+		return ptr.DomainName()
+	}
+	return answer.Name()
+}
+
+// addName adds the specified name if the name is not collected yet. The collector must be locked by the caller.
+func (collector *queryCollector) addName(name string) {
+	if slices.ContainsFunc(collector.names, func(other string) bool { return strings.EqualFold(other, name) }) {
+		return
+	}
+	collector.names = append(collector.names, name)
+}
+
+// collectMessage collects the names of the answers in the specified response message which answer the questions,
+// and passes the message to the message handler.
+func (collector *queryCollector) collectMessage(msg Message) {
+	if msg.IsResponse() {
+		collector.Lock()
+		for _, q := range collector.questions {
+			for _, answer := range msg.ResourceRecordSet() {
+				if answer.TTL() == 0 || !questionFilter(q)(answer) {
+					continue
+				}
+				collector.addName(answerName(answer))
+			}
+		}
+		collector.Unlock()
+	}
+	if collector.handler != nil {
+		collector.handler(msg)
+	}
+}
+
+// collectedNames returns the collected names.
+func (collector *queryCollector) collectedNames() []string {
+	collector.Lock()
+	defer collector.Unlock()
+	return slices.Clone(collector.names)
+}
+
+// queryCollectorSet represents the collectors of the active queries.
+type queryCollectorSet struct {
+	sync.Mutex
+	collectors []*queryCollector
+}
+
+// newQueryCollectorSet returns a new query collector set.
+func newQueryCollectorSet() *queryCollectorSet {
+	return &queryCollectorSet{
+		Mutex:      sync.Mutex{},
+		collectors: []*queryCollector{},
+	}
+}
+
+// addCollector adds the specified collector.
+func (set *queryCollectorSet) addCollector(collector *queryCollector) {
+	set.Lock()
+	defer set.Unlock()
+	set.collectors = append(set.collectors, collector)
+}
+
+// removeCollector removes the specified collector.
+func (set *queryCollectorSet) removeCollector(collector *queryCollector) {
+	set.Lock()
+	defer set.Unlock()
+	set.collectors = slices.DeleteFunc(set.collectors, func(other *queryCollector) bool {
+		return other == collector
+	})
+}
+
+// collectMessage passes the specified message to all active collectors.
+func (set *queryCollectorSet) collectMessage(msg Message) {
+	set.Lock()
+	collectors := slices.Clone(set.collectors)
+	set.Unlock()
+	for _, collector := range collectors {
+		collector.collectMessage(msg)
+	}
+}
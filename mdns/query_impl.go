@@ -15,14 +15,23 @@
 package mdns
 
 import (
+	"slices"
+	"time"
+
 	"github.com/cybergarage/go-mdns/mdns/dns"
 )
 
 type queryImp struct {
-	subtype string
-	service string
-	domain  string
-	handler MessageHandler
+	subtype         string
+	service         string
+	domain          string
+	types           []Type
+	unicastResponse bool
+	knownAnswers    ResourceRecordSet
+	handler         MessageHandler
+	maxResults      int
+	idleTimeout     time.Duration
+	filters         []QueryFilter
 }
 
 // QueryOption represents a query option.
@@ -49,6 +58,31 @@ func WithQueryDomain(domain string) QueryOption {
 	}
 }
 
+// WithQueryType sets the question types of the query, such as PTR, SRV, TXT, A, AAAA and NSEC.
+// The query has a question for each type in a single message.
+func WithQueryType(types ...Type) QueryOption {
+	return func(q *queryImp) {
+		q.types = types
+	}
+}
+
+// WithQueryUnicastResponse sets whether the first query message requests unicast responses (QU).
+// The following query messages of a browse always request multicast responses (QM).
+// RFC 6762: 5.4. Questions Requesting Unicast Responses
+func WithQueryUnicastResponse(flag bool) QueryOption {
+	return func(q *queryImp) {
+		q.unicastResponse = flag
+	}
+}
+
+// WithQueryKnownAnswers sets the known answers to include in the query messages.
+// RFC 6762: 7.1. Known-Answer Suppression.
+func WithQueryKnownAnswers(records ...ResourceRecord) QueryOption {
+	return func(q *queryImp) {
+		q.knownAnswers = append(q.knownAnswers, records...)
+	}
+}
+
 // WithQueryMessageHandler sets the message handler of the query.
 func WithQueryMessageHandler(handler MessageHandler) QueryOption {
 	return func(q *queryImp) {
@@ -56,13 +90,53 @@ func WithQueryMessageHandler(handler MessageHandler) QueryOption {
 	}
 }
 
+// WithQueryMaxResults sets the maximum number of the services to return. The query returns as soon as the services are found.
+func WithQueryMaxResults(n int) QueryOption {
+	return func(q *queryImp) {
+		q.maxResults = n
+	}
+}
+
+// WithQueryIdleTimeout sets the duration to return after no new services are found.
+func WithQueryIdleTimeout(d time.Duration) QueryOption {
+	return func(q *queryImp) {
+		q.idleTimeout = d
+	}
+}
+
+// WithQueryFilter adds the specified filter of the services to return.
+func WithQueryFilter(filter QueryFilter) QueryOption {
+	return func(q *queryImp) {
+		q.filters = append(q.filters, filter)
+	}
+}
+
+// NewAttributeFilter returns a query filter which matches the services which have the specified TXT attribute,
+// and whose attribute value is any of the specified values if the values are specified.
+// RFC 6763: 6.3. Rules for Keys in DNS-SD Key/Value Pairs
+func NewAttributeFilter(name string, values ...string) QueryFilter {
+	return func(service Service) bool {
+		attr, ok := service.LookupResourceAttribute(name)
+		if !ok {
+			return false
+		}
+		return len(values) == 0 || slices.Contains(values, attr.Value())
+	}
+}
+
 // NewQuery returns a new query instance with the specified options.
 func NewQuery(opts ...QueryOption) Query {
 	q := &queryImp{
-		subtype: "",
-		service: "",
-		domain:  DefaultQueryDomain,
-		handler: nil,
+		subtype:         "",
+		service:         "",
+		domain:          DefaultQueryDomain,
+		types:           []Type{DefaultQueryType},
+		unicastResponse: true,
+		knownAnswers:    ResourceRecordSet{},
+		handler:         nil,
+		maxResults:      0,
+		idleTimeout:     0,
+		filters:         []QueryFilter{},
 	}
 	for _, opt := range opts {
 		opt(q)
@@ -85,6 +159,38 @@ func (q *queryImp) Domain() string {
 	return q.domain
 }
 
+// Types returns the question types of the query.
+func (q *queryImp) Types() []Type {
+	return q.types
+}
+
+// IsUnicastResponse returns true if the first query message requests unicast responses, otherwise false.
+func (q *queryImp) IsUnicastResponse() bool {
+	return q.unicastResponse
+}
+
+// Questions returns the questions of the query for each question type.
+func (q *queryImp) Questions() dns.Questions {
+	cls := dns.IN
+	if q.unicastResponse {
+		cls |= QU
+	}
+	questions := dns.Questions{}
+	for _, typ := range q.types {
+		questions = append(questions, dns.NewQuestion(
+			dns.WithQuestionName(q.String()),
+			dns.WithQuestionType(typ),
+			dns.WithQuestionClass(cls),
+		))
+	}
+	return questions
+}
+
+// KnownAnswers returns the known answers to include in the query messages.
+func (q *queryImp) KnownAnswers() ResourceRecordSet {
+	return q.knownAnswers
+}
+
 // MessageHandler returns the message handler of the query if set.
 func (q *queryImp) MessageHandler() (MessageHandler, bool) {
 	if q.handler == nil {
@@ -93,6 +199,26 @@ func (q *queryImp) MessageHandler() (MessageHandler, bool) {
 	return q.handler, true
 }
 
+// MaxResults returns the maximum number of the services to return, or zero if unlimited.
+func (q *queryImp) MaxResults() int {
+	return q.maxResults
+}
+
+// IdleTimeout returns the duration to return after no new services are found, or zero if the query waits until the context is done.
+func (q *queryImp) IdleTimeout() time.Duration {
+	return q.idleTimeout
+}
+
+// MatchService returns true if the specified service matches all filters of the query, otherwise false.
+func (q *queryImp) MatchService(service Service) bool {
+	for _, filter := range q.filters {
+		if !filter(service) {
+			return false
+		}
+	}
+	return true
+}
+
 // String returns the string representation of the query.
 func (q *queryImp) String() string {
 	labels := []string{}
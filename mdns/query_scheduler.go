@@ -0,0 +1,75 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"time"
+
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+// queryScheduler schedules the successive query messages of a query.
+// The first query requests unicast responses if the query allows it and the cached answers are fresh,
+// and the following queries request multicast responses at the intervals which increase by a factor of two.
+// RFC 6762: 5.2. Continuous Multicast DNS Querying
+// RFC 6762: 5.4. Questions Requesting Unicast Responses
+type queryScheduler struct {
+	query    Query
+	cache    *cacheImpl
+	count    int
+	interval time.Duration
+}
+
+// newQueryScheduler returns a new query scheduler of the specified query which checks the answers in the specified cache.
+func newQueryScheduler(query Query, cache *cacheImpl) *queryScheduler {
+	return &queryScheduler{
+		query:    query,
+		cache:    cache,
+		count:    0,
+		interval: BrowseInitialInterval,
+	}
+}
+
+// nextQuestions returns the questions of the next query message, and advances the schedule.
+// RFC 6762: 5.4. Questions Requesting Unicast Responses
+// When a host starts up, or when a browse begins, the first query should request unicast responses to reduce the multicast traffic.
+// The following queries request multicast responses, so that the other caches on the link learn the answers.
+// The first question falls back to a multicast response if any cached answer has not been received by multicast
+// within one quarter of its TTL, so that the other caches on the link are kept up to date.
+func (scheduler *queryScheduler) nextQuestions() dns.Questions {
+	isFirstQuery := scheduler.query.IsUnicastResponse() && scheduler.count == 0
+	scheduler.count++
+	questions := dns.Questions{}
+	for _, q := range scheduler.query.Questions() {
+		cls := q.Class() &^ QU
+		if isFirstQuery && scheduler.cache.isMulticastRecently(q) {
+			cls |= QU
+		}
+		questions = append(questions, dns.NewQuestion(
+			dns.WithQuestionName(q.Name()),
+			dns.WithQuestionType(q.Type()),
+			dns.WithQuestionClass(cls),
+		))
+	}
+	return questions
+}
+
+// nextInterval returns the interval until the next query message, and doubles the following interval.
+// RFC 6762: 5.2. Continuous Multicast DNS Querying
+func (scheduler *queryScheduler) nextInterval() time.Duration {
+	interval := scheduler.interval
+	scheduler.interval = min(scheduler.interval*2, BrowseMaxInterval)
+	return interval
+}
@@ -16,8 +16,12 @@ package mdns
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
+	"fmt"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/cybergarage/go-mdns/mdns/dns"
 )
@@ -51,3 +55,257 @@ func TestQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryKnownAnswers(t *testing.T) {
+	knownAnswers := ResourceRecordSet{}
+	for n := range 100 {
+		ptr := dns.NewPTRRecord().SetDomainName(fmt.Sprintf("Test Printer %d._http._tcp.local", n))
+		ptr.SetName("_http._tcp.local")
+		ptr.SetTTL(DefaultRecordTTL)
+		knownAnswers = append(knownAnswers, ptr)
+	}
+
+	query := NewQuery(
+		WithQueryService("_http._tcp"),
+		WithQueryKnownAnswers(knownAnswers...),
+	)
+
+	// RFC 6762: 7.2. Multipacket Known-Answer Suppression
+	msgs := NewRequestsWithQuery(query)
+	if len(msgs) < 2 {
+		t.Fatalf("known answers are not split: %d", len(msgs))
+	}
+	nAnswers := 0
+	for n, msg := range msgs {
+		msg, err := dns.NewMessageWithBytes(msg.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if MaxMessageSize < len(msg.Bytes()) {
+			t.Errorf("message[%d] size %d > %d", n, len(msg.Bytes()), MaxMessageSize)
+		}
+		if isLast := n == len(msgs)-1; msg.TC() == isLast {
+			t.Errorf("message[%d] TC bit %t", n, msg.TC())
+		}
+		if hasQuestions := 0 < len(msg.Questions()); hasQuestions != (n == 0) {
+			t.Errorf("message[%d] questions %d", n, len(msg.Questions()))
+		}
+		nAnswers += len(msg.Answers())
+	}
+	if nAnswers != len(knownAnswers) {
+		t.Errorf("known answers %d != %d", nAnswers, len(knownAnswers))
+	}
+}
+
+func TestQueryDuplicateQuestionSuppression(t *testing.T) {
+	client, ok := NewClient().(*clientImpl)
+	if !ok {
+		t.Fatal("invalid client")
+	}
+	newPTR := func(instance string) ResourceRecord {
+		ptr := dns.NewPTRRecord().SetDomainName(instance)
+		ptr.SetName("_http._tcp.local")
+		ptr.SetTTL(DefaultRecordTTL)
+		return ptr
+	}
+	client.cache.addRecords("", ResourceRecordSet{newPTR("Test Printer._http._tcp.local")}, true)
+
+	questions := NewRequestWithQuery(NewQuery(WithQueryService("_http._tcp"))).Questions()
+	for _, question := range questions {
+		client.addInterest(question)
+	}
+	newQueryWithName := func(name string, cls dns.Class, knownAnswers ...ResourceRecord) Message {
+		return dns.NewRequestMessage(
+			dns.WithMessageQuestions(dns.NewQuestion(
+				dns.WithQuestionName(name),
+				dns.WithQuestionType(dns.ANY),
+				dns.WithQuestionClass(cls),
+			)),
+			dns.WithMessageAnswers(knownAnswers...),
+		)
+	}
+	newQuery := func(cls dns.Class, knownAnswers ...ResourceRecord) Message {
+		return newQueryWithName("_http._tcp.local", cls, knownAnswers...)
+	}
+
+	// RFC 6762: 7.3. Duplicate Question Suppression
+	tests := []struct {
+		name         string
+		query        Message
+		isSuppressed bool
+	}{
+		{"QU", newQuery(QU | dns.IN), false},
+		{"UnknownAnswer", newQuery(dns.IN, newPTR("Other Printer._http._tcp.local")), false},
+		{"KnownAnswer", newQuery(dns.IN, newPTR("Test Printer._http._tcp.local")), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			since := time.Now()
+			client.queryReceived(test.query)
+			if client.isQuestionSuppressed(questions, since) != test.isSuppressed {
+				t.Errorf("question suppression %t != %t", !test.isSuppressed, test.isSuppressed)
+			}
+		})
+	}
+
+	// The queries sent by this client are ignored when they are looped back.
+	since := time.Now()
+	query := newQuery(dns.IN)
+	client.querySent(query)
+	client.queryReceived(query)
+	if client.isQuestionSuppressed(questions, since) {
+		t.Errorf("own query suppresses the question")
+	}
+
+	// The questions which no browse of this client sends are not recorded,
+	// and the duplicates older than the longest query interval are removed.
+	client.queryReceived(newQueryWithName("_ipp._tcp.local", dns.IN))
+	if _, ok := client.duplicates[questionKey(newQueryWithName("_ipp._tcp.local", dns.IN).Questions()[0])]; ok {
+		t.Errorf("question of no browse is recorded")
+	}
+	oldQuestion := newQueryWithName("_printer._tcp.local", dns.IN).Questions()[0]
+	client.duplicates[questionKey(oldQuestion)] = time.Now().Add(-BrowseMaxInterval - time.Second)
+	client.queryReceived(newQuery(dns.IN, newPTR("Test Printer._http._tcp.local")))
+	if _, ok := client.duplicates[questionKey(oldQuestion)]; ok {
+		t.Errorf("old duplicate is not removed")
+	}
+}
+
+func TestQueryQuestions(t *testing.T) {
+	tests := []struct {
+		name              string
+		query             Query
+		types             []Type
+		isUnicastResponse bool
+	}{
+		{
+			name:              "Default",
+			query:             NewQuery(WithQueryService("_http._tcp")),
+			types:             []Type{dns.ANY},
+			isUnicastResponse: true,
+		},
+		{
+			name: "TypedQM",
+			query: NewQuery(
+				WithQueryService("_http._tcp"),
+				WithQueryType(dns.PTR, dns.SRV, dns.TXT),
+				WithQueryUnicastResponse(false),
+			),
+			types:             []Type{dns.PTR, dns.SRV, dns.TXT},
+			isUnicastResponse: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg, err := dns.NewMessageWithBytes(NewRequestWithQuery(test.query).Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			questions := msg.Questions()
+			if len(questions) != len(test.types) {
+				t.Fatalf("questions %d != %d", len(questions), len(test.types))
+			}
+			for n, q := range questions {
+				if q.Name() != "_http._tcp.local" || q.Type() != test.types[n] || q.IsUnicastResponse() != test.isUnicastResponse {
+					t.Errorf("invalid question: %s %s %t", q.Name(), q.Type().String(), q.IsUnicastResponse())
+				}
+			}
+		})
+	}
+}
+
+func TestQueryScheduler(t *testing.T) {
+	isUnicastResponses := func(scheduler *queryScheduler, n int) []bool {
+		flags := []bool{}
+		for range n {
+			for _, q := range scheduler.nextQuestions() {
+				flags = append(flags, q.IsUnicastResponse())
+			}
+		}
+		return flags
+	}
+
+	// RFC 6762: 5.4. Questions Requesting Unicast Responses
+	// The first query requests unicast responses, and the following queries request multicast responses.
+	cache := newCache()
+	clock := newTestClock()
+	cache.now = clock.Now
+	query := NewQuery(WithQueryService("_http._tcp"))
+	scheduler := newQueryScheduler(query, cache)
+	if flags := isUnicastResponses(scheduler, 3); !slices.Equal(flags, []bool{true, false, false}) {
+		t.Errorf("invalid unicast response bits: %v", flags)
+	}
+	scheduler = newQueryScheduler(NewQuery(WithQueryService("_http._tcp"), WithQueryUnicastResponse(false)), cache)
+	if flags := isUnicastResponses(scheduler, 2); !slices.Equal(flags, []bool{false, false}) {
+		t.Errorf("invalid unicast response bits: %v", flags)
+	}
+
+	// The first query falls back to a multicast response if the cached answer has not been multicast within one quarter of its TTL.
+	ptr := dns.NewPTRRecord().SetDomainName("Test Printer._http._tcp.local")
+	ptr.SetName("_http._tcp.local")
+	ptr.SetTTL(DefaultRecordTTL)
+	cache.addRecords("", ResourceRecordSet{ptr}, true)
+	if flags := isUnicastResponses(newQueryScheduler(query, cache), 1); !slices.Equal(flags, []bool{true}) {
+		t.Errorf("invalid unicast response bits: %v", flags)
+	}
+	clock.Advance(time.Duration(DefaultRecordTTL/4) * time.Second)
+	if flags := isUnicastResponses(newQueryScheduler(query, cache), 1); !slices.Equal(flags, []bool{false}) {
+		t.Errorf("invalid unicast response bits: %v", flags)
+	}
+	cache.addRecords("", ResourceRecordSet{ptr}, false)
+	if flags := isUnicastResponses(newQueryScheduler(query, cache), 1); !slices.Equal(flags, []bool{false}) {
+		t.Errorf("invalid unicast response bits: %v", flags)
+	}
+	cache.addRecords("", ResourceRecordSet{ptr}, true)
+	if flags := isUnicastResponses(newQueryScheduler(query, cache), 1); !slices.Equal(flags, []bool{true}) {
+		t.Errorf("invalid unicast response bits: %v", flags)
+	}
+
+	// RFC 6762: 5.2. Continuous Multicast DNS Querying
+	intervals := []time.Duration{}
+	for range 3 {
+		intervals = append(intervals, scheduler.nextInterval())
+	}
+	if !slices.Equal(intervals, []time.Duration{BrowseInitialInterval, 2 * BrowseInitialInterval, 4 * BrowseInitialInterval}) {
+		t.Errorf("invalid intervals: %v", intervals)
+	}
+}
+
+func TestQueryFilter(t *testing.T) {
+	service := newTestService(t)
+	tests := []struct {
+		name      string
+		query     Query
+		isMatched bool
+	}{
+		{"NoFilter", NewQuery(), true},
+		{"Attribute", NewQuery(WithQueryFilter(NewAttributeFilter("path"))), true},
+		{"AttributeValue", NewQuery(WithQueryFilter(NewAttributeFilter("path", "/", "/index.html"))), true},
+		{"UnmatchedAttribute", NewQuery(WithQueryFilter(NewAttributeFilter("version"))), false},
+		{"UnmatchedAttributeValue", NewQuery(WithQueryFilter(NewAttributeFilter("path", "/"))), false},
+		{
+			"MultipleFilters",
+			NewQuery(
+				WithQueryFilter(NewAttributeFilter("path")),
+				WithQueryFilter(func(service Service) bool { return service.Port() == 631 }),
+			),
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.query.MatchService(service) != test.isMatched {
+				t.Errorf("service match %t != %t", !test.isMatched, test.isMatched)
+			}
+		})
+	}
+}
+
+func TestQueryStreamError(t *testing.T) {
+	// The stream is not returned if the query cannot be sent, so that the caller can distinguish the failure from no answers.
+	client := NewClient()
+	services, err := client.QueryStream(context.Background(), NewQuery(WithQueryService("_http._tcp")))
+	if err == nil || services != nil {
+		t.Errorf("query stream of the stopped client should fail: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+// questionSuppressor records the query messages sent by this client and the duplicate questions sent by other hosts.
+// RFC 6762: 7.3. Duplicate Question Suppression
+type questionSuppressor struct {
+	sync.Mutex
+	sentQueries map[string]time.Time
+	duplicates  map[string]time.Time
+}
+
+// newQuestionSuppressor returns a new question suppressor.
+func newQuestionSuppressor() *questionSuppressor {
+	return &questionSuppressor{
+		Mutex:       sync.Mutex{},
+		sentQueries: map[string]time.Time{},
+		duplicates:  map[string]time.Time{},
+	}
+}
+
+// questionKey returns the key of the specified question without the unicast response bit.
+func questionKey(q dns.Question) string {
+	return cacheKey(q.Name(), q.Type(), q.Class()&^dns.QU)
+}
+
+// querySent records the specified query message sent by this client to ignore it when it is looped back.
+func (suppressor *questionSuppressor) querySent(msg Message) {
+	suppressor.Lock()
+	defer suppressor.Unlock()
+	now := time.Now()
+	for key, sent := range suppressor.sentQueries {
+		if MulticastInterval < now.Sub(sent) {
+			delete(suppressor.sentQueries, key)
+		}
+	}
+	suppressor.sentQueries[string(msg.Bytes())] = now
+}
+
+// isSentQuery returns true if the specified query message was sent by this client recently.
+func (suppressor *questionSuppressor) isSentQuery(msg Message) bool {
+	suppressor.Lock()
+	defer suppressor.Unlock()
+	_, ok := suppressor.sentQueries[string(msg.Bytes())]
+	return ok
+}
+
+// duplicateReceived records that another host sent the specified question.
+// The duplicates older than BrowseMaxInterval are removed because they suppress no more queries.
+func (suppressor *questionSuppressor) duplicateReceived(q dns.Question) {
+	suppressor.Lock()
+	defer suppressor.Unlock()
+	now := time.Now()
+	for key, received := range suppressor.duplicates {
+		if BrowseMaxInterval < now.Sub(received) {
+			delete(suppressor.duplicates, key)
+		}
+	}
+	suppressor.duplicates[questionKey(q)] = now
+}
+
+// isQuestionSuppressed returns true if other hosts sent all the specified questions after the specified time,
+// and so the next query of the questions should be treated as having been sent.
+func (suppressor *questionSuppressor) isQuestionSuppressed(questions []dns.Question, since time.Time) bool {
+	suppressor.Lock()
+	defer suppressor.Unlock()
+	if len(questions) == 0 {
+		return false
+	}
+	for _, q := range questions {
+		received, ok := suppressor.duplicates[questionKey(q)]
+		if !ok || received.Before(since) {
+			return false
+		}
+	}
+	return true
+}
+
+// queryReceived records the questions of the specified query from another host which duplicate the questions
+// of the active browses of this client. The other questions are ignored because this client never sends them.
+// RFC 6762: 7.3. Duplicate Question Suppression
+// If a host is planning to transmit a query, and it sees another host on the network send a query containing the same "QM" question,
+// and the Known-Answer Section of that query does not contain any records that this host would not also put in its own
+// Known-Answer Section, then this host should treat its own query as having been sent.
+func (client *clientImpl) queryReceived(msg Message) {
+	// The known answers of a truncated query continue in the following messages.
+	if msg.TC() || client.isSentQuery(msg) {
+		return
+	}
+	interests := client.interests()
+	isInterested := func(q dns.Question) bool {
+		return slices.ContainsFunc(interests, func(interest dns.Question) bool {
+			return questionKey(interest) == questionKey(q)
+		})
+	}
+	for _, q := range msg.Questions() {
+		if q.IsUnicastResponse() || !isInterested(q) {
+			continue
+		}
+		knownAnswers := client.cache.knownAnswers([]dns.Question{q})
+		isDuplicate := true
+		for _, answer := range msg.Answers() {
+			if questionFilter(q)(answer) && !slices.ContainsFunc(knownAnswers, answer.Equal) {
+				isDuplicate = false
+				break
+			}
+		}
+		if isDuplicate {
+			client.duplicateReceived(q)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-logger/log"
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+// RegistrationState represents a registration state of a service.
+type RegistrationState int
+
+const (
+	// RegistrationProbing represents that the unique names of the service are being probed.
+	RegistrationProbing RegistrationState = iota
+	// RegistrationRenamed represents that the service has been renamed to resolve a name conflict.
+	RegistrationRenamed
+	// RegistrationRegistered represents that the unique names of the service are claimed, and the service is published.
+	RegistrationRegistered
+)
+
+// RFC 6762 - Multicast DNS.
+const (
+	// ConflictLimit is the number of conflicts within ConflictPeriod to slow down probing.
+	// 8.1. Probing
+	ConflictLimit = 15
+	// ConflictPeriod is the period to count conflicts.
+	// 8.1. Probing
+	ConflictPeriod = 10 * time.Second
+	// ConflictDelay is the time to wait before each successive probe after ConflictLimit conflicts.
+	// 8.1. Probing
+	ConflictDelay = 5 * time.Second
+)
+
+var (
+	instanceNumberRegex = regexp.MustCompile(`^(.*) \((\d+)\)$`)
+	hostNumberRegex     = regexp.MustCompile(`^(.*)-(\d+)$`)
+)
+
+// String returns the string representation.
+func (state RegistrationState) String() string {
+	switch state {
+	case RegistrationProbing:
+		return "probing"
+	case RegistrationRenamed:
+		return "renamed"
+	case RegistrationRegistered:
+		return "registered"
+	}
+	return "unknown"
+}
+
+// nextName returns the next name of the specified name with the number, such as "Printer (2)" or "host-2".
+func nextName(name string, re *regexp.Regexp, format string) string {
+	n := 2
+	if m := re.FindStringSubmatch(name); m != nil {
+		if v, err := strconv.Atoi(m[2]); err == nil {
+			name = m[1]
+			n = v + 1
+		}
+	}
+	return fmt.Sprintf(format, name, n)
+}
+
+// renameService returns a copy of the specified service renamed to resolve the conflict with the specified record.
+// The instance name is renamed if the record conflicts with the service records, otherwise the host name is renamed.
+// RFC 6762: 9. Conflict Resolution.
+func renameService(service Service, host string, conflict ResourceRecord) (Service, error) {
+	instance, serviceType, err := splitServiceName(service.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	name := service.Name()
+	host = serviceHost(service, host)
+	if conflict.IsName(dns.NewNameWithStrings(service.Name(), serviceDomain(service))) {
+		name = dns.NewNameWithStrings(nextName(instance, instanceNumberRegex, "%s (%d)"), serviceType)
+	} else {
+		label, domain, _ := strings.Cut(host, dns.LabelSeparator)
+		host = dns.NewNameWithStrings(nextName(label, hostNumberRegex, "%s-%d"), domain)
+	}
+
+	opts := []ServiceOptions{
+		WithServiceName(name),
+		WithServiceDomain(service.Domain()),
+		WithServiceHost(host),
+		WithServicePort(service.Port()),
+		WithServiceAddresses(service.Addresses()...),
+		WithServiceSubtypes(service.Subtypes()...),
+	}
+	for _, attr := range service.ResourceAttributes() {
+		opts = append(opts, WithServiceAttribute(attr.Name(), attr.Value()))
+	}
+	return NewService(opts...)
+}
+
+// SetRegistrationHandler sets the handler to be notified of the registration state changes of the services.
+func (server *Server) SetRegistrationHandler(handler RegistrationHandler) {
+	server.Lock()
+	defer server.Unlock()
+	server.registrationHandler = handler
+}
+
+// notifyRegistrationState notifies the specified registration state of the service to the registration handler.
+func (server *Server) notifyRegistrationState(service Service, state RegistrationState) {
+	server.Lock()
+	handler := server.registrationHandler
+	server.Unlock()
+	if handler != nil {
+		handler(service, state)
+	}
+}
+
+// throttleConflicts adds a new conflict to the specified conflict times, and waits before the next probe
+// if too many conflicts occurred recently. throttleConflicts returns the conflict times within ConflictPeriod.
+// RFC 6762: 8.1. Probing
+// If fifteen conflicts occur within any ten-second period, then the host MUST wait at least
+// five seconds before each successive additional probe attempt.
+func (server *Server) throttleConflicts(conflicts []time.Time) []time.Time {
+	now := time.Now()
+	conflicts = slices.DeleteFunc(conflicts, func(t time.Time) bool {
+		return ConflictPeriod < now.Sub(t)
+	})
+	conflicts = append(conflicts, now)
+	if ConflictLimit <= len(conflicts) {
+		server.sleep(ConflictDelay)
+	}
+	return conflicts
+}
+
+// probeServices probes the host name and the unique names of all registered services again before publishing them.
+// RFC 6762: 8.1. Probing
+func (server *Server) probeServices() error {
+	server.Lock()
+	services := server.Services()
+	server.Clear()
+	server.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(services)+1)
+	wg.Go(func() {
+		errs[len(services)] = server.claimHost()
+	})
+	for n, service := range services {
+		wg.Go(func() {
+			errs[n] = server.claimService(service)
+		})
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// claimService probes the unique names of the specified service, and adds the service to publish.
+// If any other host already uses the names, the service is renamed and probed again until the names are claimed.
+// RFC 6762: 8.1. Probing
+// RFC 6762: 9. Conflict Resolution.
+func (server *Server) claimService(service Service) error {
+	conflicts := []time.Time{}
+	for server.IsRunning() {
+		records, err := server.serviceRecords(service)
+		if err != nil {
+			return err
+		}
+		server.notifyRegistrationState(service, RegistrationProbing)
+		conflict, err := server.probe(records)
+		if err != nil {
+			return err
+		}
+		if conflict == nil {
+			server.Lock()
+			server.AddService(service)
+			server.Unlock()
+			server.notifyRegistrationState(service, RegistrationRegistered)
+			server.announceService(service)
+			return nil
+		}
+
+		service, err = renameService(service, server.Host(), conflict)
+		if err != nil {
+			return err
+		}
+		server.notifyRegistrationState(service, RegistrationRenamed)
+		conflicts = server.throttleConflicts(conflicts)
+	}
+
+	// The service will be probed when the server starts.
+	if _, err := server.serviceRecords(service); err != nil {
+		return err
+	}
+	server.Lock()
+	defer server.Unlock()
+	server.AddService(service)
+	return nil
+}
+
+// unpublishedRecords returns the records in the specified records which are not published by this server.
+func (server *Server) unpublishedRecords(records ResourceRecordSet) ResourceRecordSet {
+	publishedRecords := server.publishedRecords()
+	return slices.DeleteFunc(slices.Clone(records), func(record ResourceRecord) bool {
+		return slices.ContainsFunc(publishedRecords, record.Equal)
+	})
+}
+
+// claimedRecords returns the records in the specified records which another host claims.
+// Goodbye records are ignored because they withdraw the records rather than claim them, and the records which
+// this server multicast recently are also ignored because they are the own packets looped back after the records are updated.
+// RFC 6762: 10.1. Goodbye Packets
+func (server *Server) claimedRecords(records ResourceRecordSet) ResourceRecordSet {
+	return slices.DeleteFunc(server.unpublishedRecords(records), func(record ResourceRecord) bool {
+		return record.TTL() == 0 || server.isOwnMulticast(record)
+	})
+}
+
+// resolveConflicts probes the registered services again whose unique records conflict with the specified records of another host.
+// RFC 6762: 9. Conflict Resolution.
+func (server *Server) resolveConflicts(records ResourceRecordSet) {
+	host := server.Host()
+	hostRecords := server.hostRecords(nil).LookupRecordSetByName(host)
+	if _, ok := conflictingRecord(hostRecords, records); ok {
+		go func() {
+			if err := server.claimHost(); err != nil {
+				log.Error(err)
+			}
+		}()
+	}
+
+	server.Lock()
+	conflictedServices := []Service{}
+	for _, service := range server.Services() {
+		serviceRecords, err := newServiceRecords(service, host)
+		if err != nil {
+			continue
+		}
+		if _, ok := conflictingRecord(uniqueRecords(serviceRecords), records); ok {
+			conflictedServices = append(conflictedServices, service)
+		}
+	}
+	for _, service := range conflictedServices {
+		server.RemoveService(service)
+	}
+	server.Unlock()
+
+	for _, service := range conflictedServices {
+		go func() {
+			if err := server.claimService(service); err != nil {
+				log.Error(err)
+			}
+		}()
+	}
+}
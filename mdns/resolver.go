@@ -0,0 +1,131 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+// newQuestions returns the questions of the specified name and types in the IN class.
+func newQuestions(name string, types ...dns.Type) []dns.Question {
+	questions := []dns.Question{}
+	for _, typ := range types {
+		questions = append(questions, dns.NewQuestion(
+			dns.WithQuestionName(name),
+			dns.WithQuestionType(typ),
+			dns.WithQuestionClass(dns.IN),
+		))
+	}
+	return questions
+}
+
+// unresolvedQuestions returns the questions to resolve the specified service instance which are not answered in the cache yet,
+// that is, the SRV and TXT questions of the instance, and then the A and AAAA questions of the SRV target.
+// RFC 6763: 12. Additional Record Generation
+// RFC 6762: 5.2. Continuous Multicast DNS Querying
+func (cache *cacheImpl) unresolvedQuestions(name string) []dns.Question {
+	types := []dns.Type{}
+	for _, typ := range []dns.Type{dns.SRV, dns.TXT} {
+		if len(cache.LookupRecords(name, typ, dns.IN)) == 0 {
+			types = append(types, typ)
+		}
+	}
+	if 0 < len(types) {
+		return newQuestions(name, types...)
+	}
+	questions := []dns.Question{}
+	for _, record := range cache.LookupRecords(name, dns.SRV, dns.IN) {
+		srv, ok := record.(dns.SRVRecord)
+		if !ok {
+			continue
+		}
+		if 0 < len(cache.LookupRecords(srv.Target(), dns.A, dns.IN)) || 0 < len(cache.LookupRecords(srv.Target(), dns.AAAA, dns.IN)) {
+			continue
+		}
+		questions = append(questions, newQuestions(srv.Target(), dns.A, dns.AAAA)...)
+	}
+	return questions
+}
+
+// Resolve sends the follow-up queries to resolve the SRV and TXT records of the specified service instance name,
+// such as "My Printer._http._tcp.local", and the address records of the SRV target which are not answered yet,
+// and returns the service composed of the cached records.
+// RFC 6763: 12. Additional Record Generation
+func (client *clientImpl) Resolve(ctx context.Context, name string) (Service, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultQueryTimeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(CacheRefreshInterval)
+	defer ticker.Stop()
+
+	lastQuestions := []dns.Question{}
+	lastSent := time.Time{}
+	interval := BrowseInitialInterval
+	for {
+		updates := client.cache.updates()
+		questions := client.cache.unresolvedQuestions(name)
+		if len(questions) == 0 {
+			return client.resolvedService(name)
+		}
+		isChanged := !slices.EqualFunc(questions, lastQuestions, func(q1, q2 dns.Question) bool {
+			return q1.Equal(q2)
+		})
+		// RFC 6762: 5.2. Continuous Multicast DNS Querying
+		// The intervals between the queries of the same questions increase by a factor of two.
+		if isChanged || interval <= time.Since(lastSent) {
+			if isChanged {
+				interval = BrowseInitialInterval
+			} else {
+				interval = min(interval*2, BrowseMaxInterval)
+			}
+			msg := dns.NewRequestMessage(
+				dns.WithMessageQuestions(questions...),
+				dns.WithMessageAnswers(client.cache.knownAnswers(questions)...),
+			)
+			if err := client.sendQueryMessages([]Message{msg}); err != nil {
+				return nil, err
+			}
+			lastQuestions = questions
+			lastSent = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			// The service is returned even if the addresses are not resolved until the context is done.
+			return client.resolvedService(name)
+		case <-updates:
+		case <-ticker.C:
+		}
+	}
+}
+
+// resolvedService returns the service of the specified instance name if the SRV record of the instance is cached.
+func (client *clientImpl) resolvedService(name string) (Service, error) {
+	if len(client.cache.LookupRecords(name, dns.SRV, dns.IN)) == 0 {
+		return nil, fmt.Errorf("service (%s) is %w", name, ErrNotFound)
+	}
+	service, ok := client.cache.LookupService(name)
+	if !ok {
+		return nil, fmt.Errorf("service (%s) is %w", name, ErrNotFound)
+	}
+	return service, nil
+}
@@ -0,0 +1,533 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-mdns/mdns/dns"
+	"github.com/cybergarage/go-mdns/mdns/transport"
+)
+
+// RFC 6762 - Multicast DNS.
+const (
+	// MinResponseDelay is the minimum random delay before responding with shared records.
+	// 6. Responding
+	MinResponseDelay = 20 * time.Millisecond
+	// MaxResponseDelay is the maximum random delay before responding with shared records.
+	// 6. Responding
+	MaxResponseDelay = 120 * time.Millisecond
+	// DefaultHostRecordTTL is the TTL of records containing a host name, such as SRV, A and AAAA records.
+	// 10. Resource Record TTL Values and Cache Coherency.
+	DefaultHostRecordTTL = 120
+	// DefaultRecordTTL is the TTL of the other records, such as PTR and TXT records.
+	// 10. Resource Record TTL Values and Cache Coherency.
+	DefaultRecordTTL = 4500
+	// LegacyUnicastTTL is the maximum TTL of the records in legacy unicast responses.
+	// 6.7. Legacy Unicast Responses
+	LegacyUnicastTTL = 10
+)
+
+// answerAggregator aggregates the shared answers to near-simultaneous queries into a single response for each interface.
+type answerAggregator struct {
+	sync.Mutex
+	answers map[string]ResourceRecordSet
+}
+
+// newAnswerAggregator returns a new answer aggregator.
+func newAnswerAggregator() *answerAggregator {
+	return &answerAggregator{
+		Mutex:   sync.Mutex{},
+		answers: map[string]ResourceRecordSet{},
+	}
+}
+
+// aggregateAnswers adds the specified answers into the pending response of the specified key,
+// and returns true if the pending response is newly created and the caller should flush it later.
+func (agg *answerAggregator) aggregateAnswers(key string, answers ResourceRecordSet) bool {
+	agg.Lock()
+	defer agg.Unlock()
+	pendingAnswers, ok := agg.answers[key]
+	for _, answer := range answers {
+		if slices.ContainsFunc(pendingAnswers, answer.Equal) {
+			continue
+		}
+		pendingAnswers = append(pendingAnswers, answer)
+	}
+	agg.answers[key] = pendingAnswers
+	return !ok
+}
+
+// flushAnswers removes and returns the pending answers of the specified key.
+func (agg *answerAggregator) flushAnswers(key string) ResourceRecordSet {
+	agg.Lock()
+	defer agg.Unlock()
+	answers := agg.answers[key]
+	delete(agg.answers, key)
+	return answers
+}
+
+// suppressAnswers removes the pending answers of the specified key which another host has already answered
+// with the specified records.
+// RFC 6762: 7.4. Duplicate Answer Suppression
+// If a host is planning to send an answer, and it sees another host on the network send a response message containing the answer record,
+// and the TTL in that record is not less than the TTL this host would have given, then this host should treat its own answer as having been sent.
+func (agg *answerAggregator) suppressAnswers(key string, records ResourceRecordSet) {
+	agg.Lock()
+	defer agg.Unlock()
+	pendingAnswers, ok := agg.answers[key]
+	if !ok {
+		return
+	}
+	agg.answers[key] = slices.DeleteFunc(pendingAnswers, func(answer ResourceRecord) bool {
+		return slices.ContainsFunc(records, func(record ResourceRecord) bool {
+			return record.Equal(answer) && answer.TTL() <= record.TTL()
+		})
+	})
+}
+
+// splitServiceName splits the specified service name into the instance name and the service type.
+func splitServiceName(name string) (string, string, error) {
+	labels := dns.SplitName(name)
+	if len(labels) < 3 {
+		return "", "", fmt.Errorf("%w service name: %s", ErrInvalid, name)
+	}
+	n := len(labels) - 2
+	return strings.Join(labels[:n], dns.LabelSeparator), strings.Join(labels[n:], dns.LabelSeparator), nil
+}
+
+// serviceDomain returns the domain of the specified service.
+func serviceDomain(service Service) string {
+	if len(service.Domain()) == 0 {
+		return LocalDomain
+	}
+	return service.Domain()
+}
+
+// serviceHost returns the target host name of the specified service, or the specified host name if the service has no host name.
+func serviceHost(service Service, host string) string {
+	if len(service.Host()) != 0 {
+		host = service.Host()
+	}
+	if !strings.Contains(host, dns.LabelSeparator) {
+		host = dns.NewNameWithStrings(host, serviceDomain(service))
+	}
+	return host
+}
+
+// newServiceRecords returns the resource records to publish the specified service.
+// The SRV record targets the specified host name if the service has no host name.
+// RFC 6763: 4. Service Instance Enumeration (Browsing)
+// RFC 6763: 6. Data Syntax for DNS-SD TXT Records.
+// RFC 6763: 7.1. Selective Instance Enumeration (Subtypes).
+// RFC 6763: 9. Service Type Enumeration.
+func newServiceRecords(service Service, host string) (ResourceRecordSet, error) {
+	_, serviceType, err := splitServiceName(service.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	domain := serviceDomain(service)
+	instanceName := dns.NewNameWithStrings(service.Name(), domain)
+	host = serviceHost(service, host)
+
+	ptr := dns.NewPTRRecord().SetDomainName(instanceName)
+	ptr.SetName(dns.NewNameWithStrings(serviceType, domain))
+	ptr.SetTTL(DefaultRecordTTL)
+
+	srv := dns.NewSRVRecord().SetPort(uint(service.Port())).SetTarget(host)
+	srv.SetName(instanceName)
+	srv.SetTTL(DefaultHostRecordTTL)
+	srv.SetCacheFlush(true)
+
+	txt := dns.NewTXTRecord().SetAttributes(service.ResourceAttributes())
+	txt.SetName(instanceName)
+	txt.SetTTL(DefaultRecordTTL)
+	txt.SetCacheFlush(true)
+
+	// RFC 6763: 9. Service Type Enumeration
+	// A DNS query for PTR records with the name "_services._dns-sd._udp.<Domain>" yields a set of PTR records,
+	// where the rdata of each PTR record is the two-label <Service> name, plus the same domain.
+	typePtr := dns.NewPTRRecord().SetDomainName(dns.NewNameWithStrings(serviceType, domain))
+	typePtr.SetName(dns.NewNameWithStrings(ServiceTypeEnumerationName, domain))
+	typePtr.SetTTL(DefaultRecordTTL)
+
+	records := ResourceRecordSet{ptr, srv, txt, typePtr}
+
+	// RFC 6763: 7.1. Selective Instance Enumeration (Subtypes)
+	// A subtype PTR record "<sub>._sub.<Service>.<Domain>" points to the instance name in addition to the service type PTR record.
+	for _, subtype := range service.Subtypes() {
+		subPtr := dns.NewPTRRecord().SetDomainName(instanceName)
+		subPtr.SetName(dns.NewNameWithStrings(subtype, Subtype, serviceType, domain))
+		subPtr.SetTTL(DefaultRecordTTL)
+		records = append(records, subPtr)
+	}
+	records = append(records, newAddressRecords(host, service.Addresses())...)
+
+	return records, nil
+}
+
+// newAddressRecords returns the A and AAAA records of the specified host.
+func newAddressRecords(host string, addrs []net.IP) ResourceRecordSet {
+	records := ResourceRecordSet{}
+	for _, addr := range addrs {
+		var record ResourceRecord
+		if addr.To4() != nil {
+			record = dns.NewARecordWithAddress(addr)
+		} else {
+			record = dns.NewAAAARecord().SetAddress(addr)
+		}
+		record.SetName(host)
+		record.SetTTL(DefaultHostRecordTTL)
+		record.SetCacheFlush(true)
+		records = append(records, record)
+	}
+	return records
+}
+
+// lookupAnswerRecords returns the records which answer the specified question.
+func lookupAnswerRecords(records ResourceRecordSet, q dns.Question) ResourceRecordSet {
+	answers := ResourceRecordSet{}
+	for _, record := range records {
+		if !record.IsName(q.Name()) {
+			continue
+		}
+		if !q.Type().Equal(record.Type()) {
+			continue
+		}
+		if !q.Class().Equal(record.Class()) {
+			continue
+		}
+		answers = append(answers, record)
+	}
+	return answers
+}
+
+// lookupAdditionalRecords returns the additional records for the specified answers.
+// RFC 6763: 12. DNS Additional Record Generation.
+func lookupAdditionalRecords(records ResourceRecordSet, answers ResourceRecordSet) ResourceRecordSet {
+	additions := ResourceRecordSet{}
+	hasRecord := func(record ResourceRecord) bool {
+		return slices.ContainsFunc(answers, record.Equal) || slices.ContainsFunc(additions, record.Equal)
+	}
+	appendRecords := func(name string, types ...dns.Type) {
+		for _, record := range records.LookupRecordSetByName(name) {
+			if !slices.Contains(types, record.Type()) || hasRecord(record) {
+				continue
+			}
+			additions = append(additions, record)
+		}
+	}
+
+	// 12.1. PTR Records
+	for _, record := range answers {
+		if ptr, ok := record.(dns.PTRRecord); ok {
+			appendRecords(ptr.DomainName(), dns.SRV, dns.TXT)
+		}
+	}
+
+	// 12.2. SRV Records
+	srvRecords := append(ResourceRecordSet{}, answers...)
+	srvRecords = append(srvRecords, additions...)
+	for _, record := range srvRecords {
+		if srv, ok := record.(dns.SRVRecord); ok {
+			appendRecords(srv.Target(), dns.A, dns.AAAA)
+			// RFC 6762: 6.1. Negative Responses
+			// When the host has only IPv4 or only IPv6 addresses, the NSEC record asserting the nonexistence
+			// of the other address records is placed into the additional section.
+			hostRecords := records.LookupRecordSetByName(srv.Target())
+			if len(hostRecords.LookupARecordSet()) == 0 || len(hostRecords.LookupAAAARecordSet()) == 0 {
+				if nsec, ok := negativeRecord(records, srv.Target()); ok && !hasRecord(nsec) {
+					additions = append(additions, nsec)
+				}
+			}
+		}
+	}
+
+	return additions
+}
+
+// negativeRecord returns a NSEC record which asserts that no other record types than the specified records exist at the specified name.
+// The NSEC record is generated only for the names which have unique records, that is, the names owned by this host exclusively.
+// RFC 6762: 6.1. Negative Responses
+func negativeRecord(records ResourceRecordSet, name string) (ResourceRecord, bool) {
+	nameRecords := records.LookupRecordSetByName(name)
+	if len(uniqueRecords(nameRecords)) == 0 {
+		return nil, false
+	}
+	types := []dns.Type{}
+	ttl := uint(DefaultRecordTTL)
+	for _, record := range nameRecords {
+		types = append(types, record.Type())
+		ttl = min(ttl, record.TTL())
+	}
+	// The 'Next Domain Name' field contains the record's own name.
+	nsec := dns.NewNSECRecord().SetNextDomainName(name).SetTypes(types...)
+	nsec.SetName(name)
+	nsec.SetTTL(ttl)
+	nsec.SetCacheFlush(true)
+	return nsec, true
+}
+
+// isKnownAnswer returns true if the specified record is included in the known answers with at least half the true TTL remaining.
+// RFC 6762: 7.1. Known-Answer Suppression
+func isKnownAnswer(knownAnswers ResourceRecordSet, record ResourceRecord) bool {
+	return slices.ContainsFunc(knownAnswers, func(knownAnswer ResourceRecord) bool {
+		return knownAnswer.Equal(record) && record.TTL() <= knownAnswer.TTL()*2
+	})
+}
+
+// serviceRecords returns the resource records to publish the specified service on this server.
+func (server *Server) serviceRecords(service Service) (ResourceRecordSet, error) {
+	return newServiceRecords(service, server.Host())
+}
+
+// publishedRecords returns all resource records of the host and the registered services.
+func (server *Server) publishedRecords() ResourceRecordSet {
+	return server.interfaceRecords(nil)
+}
+
+// interfaceRecords returns the resource records of the host and the registered services
+// to publish on the interface which has the specified local address.
+// RFC 6762: 14. Considerations for Multiple Interfaces
+func (server *Server) interfaceRecords(to dns.Addr) ResourceRecordSet {
+	var ifaddr net.IP
+	if to != nil {
+		ifaddr = to.IP()
+	}
+	records := server.hostRecords(ifaddr)
+
+	server.Lock()
+	defer server.Unlock()
+
+	for _, service := range server.Services() {
+		serviceRecords, err := newServiceRecords(service, server.host)
+		if err != nil {
+			continue
+		}
+		for _, record := range serviceRecords {
+			if slices.ContainsFunc(records, record.Equal) {
+				continue
+			}
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// answersForQuery returns the records which answer the questions in the specified query except the known answers.
+// The negative answers are returned for the questions about nonexistent record types of the names owned by this host.
+func answersForQuery(records ResourceRecordSet, query Message) ResourceRecordSet {
+	answers := ResourceRecordSet{}
+	for _, q := range query.Questions() {
+		qAnswers := lookupAnswerRecords(records, q)
+		// RFC 6762: 6.1. Negative Responses
+		// Any time a responder receives a query for a name for which it has verified exclusive ownership,
+		// for a type for which that name has no records, the responder MUST respond asserting the nonexistence
+		// of that record using a DNS NSEC record.
+		if len(qAnswers) == 0 && q.Class().Equal(dns.IN) {
+			if nsec, ok := negativeRecord(records, q.Name()); ok {
+				qAnswers = append(qAnswers, nsec)
+			}
+		}
+		for _, record := range qAnswers {
+			if slices.ContainsFunc(answers, record.Equal) {
+				continue
+			}
+			if isKnownAnswer(query.Answers(), record) {
+				continue
+			}
+			answers = append(answers, record)
+		}
+	}
+	return answers
+}
+
+// responseForAnswers returns a response message with the specified answers and the additional records for them.
+func responseForAnswers(records ResourceRecordSet, answers ResourceRecordSet, opts ...dns.MessageOption) Message {
+	opts = append(opts,
+		dns.WithMessageAnswers(answers...),
+		dns.WithMessageAdditions(lookupAdditionalRecords(records, answers)...),
+	)
+	return dns.NewResponseMessage(opts...)
+}
+
+// unicastResponseOptions returns the message options to send the response to the querier of the specified query by unicast.
+// RFC 6762: 18.1. ID (Query Identifier)
+func unicastResponseOptions(query Message) []dns.MessageOption {
+	return []dns.MessageOption{
+		dns.WithMessageID(query.ID()),
+		dns.WithMessageTo(query.From()),
+	}
+}
+
+// isLegacyUnicastQuery returns true if the specified query is sent from a source port other than 5353.
+// RFC 6762: 6.7. Legacy Unicast Responses
+func isLegacyUnicastQuery(query Message) bool {
+	from := query.From()
+	return from != nil && from.Port() != transport.Port
+}
+
+// isDirectUnicastQuery returns true if the specified query is sent directly to the unicast address of this host.
+// RFC 6762: 5.5. Direct Unicast Queries to Port 5353
+func isDirectUnicastQuery(query Message) bool {
+	from := query.From()
+	return from != nil && from.Transport().Is(dns.TransportUDP)
+}
+
+// isLocalLinkAddress returns true if the specified address is a link-local address or an address in the local subnets.
+// RFC 6762: 5.5. Direct Unicast Queries to Port 5353
+func isLocalLinkAddress(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return true
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// legacyResponseForAnswers returns a conventional unicast response message for the specified legacy query.
+// The TTLs of the specified records are overwritten.
+// RFC 6762: 6.7. Legacy Unicast Responses
+func legacyResponseForAnswers(records ResourceRecordSet, query Message, answers ResourceRecordSet) Message {
+	additions := lookupAdditionalRecords(records, answers)
+	for _, record := range append(slices.Clone(answers), additions...) {
+		// The resource record TTL given in a legacy unicast response SHOULD NOT be greater than ten seconds,
+		// and the cache-flush bit MUST NOT be set in legacy unicast responses.
+		record.SetTTL(min(record.TTL(), LegacyUnicastTTL))
+		record.SetCacheFlush(false)
+	}
+	opts := unicastResponseOptions(query)
+	opts = append(opts,
+		dns.WithMessageQuestions(query.Questions()...),
+		dns.WithMessageAnswers(answers...),
+		dns.WithMessageAdditions(additions...),
+	)
+	return dns.NewResponseMessage(opts...)
+}
+
+// responseForQuery returns a response message for the specified query message, or nil if there is no answer.
+// The response to a legacy or direct unicast query is returned immediately by unicast.
+// The response is also returned immediately if all answers are unique records, otherwise it is delayed randomly,
+// and the answers to the near-simultaneous queries received on the same interface are aggregated into the first response.
+// The response to a question requesting a unicast response is multicast instead if this responder has not multicast
+// the answers within one quarter of their TTLs. This is the responder side of the fallback, and the querier side
+// is done by the query scheduler, which sends a multicast question for the stale answers in the querier cache.
+// RFC 6762: 5.4. Questions Requesting Unicast Responses
+// RFC 6762: 5.5. Direct Unicast Queries to Port 5353
+// RFC 6762: 6. Responding
+// RFC 6762: 6.7. Legacy Unicast Responses
+func (server *Server) responseForQuery(query Message) (Message, error) {
+	records := server.interfaceRecords(query.To())
+	answers := answersForQuery(records, query)
+	if len(answers) == 0 {
+		return nil, nil
+	}
+
+	switch {
+	case isLegacyUnicastQuery(query):
+		return legacyResponseForAnswers(records, query, answers), nil
+	case isDirectUnicastQuery(query):
+		// If a Multicast DNS responder receives a unicast query from a source address not on the local link, it MUST silently ignore the query.
+		if !isLocalLinkAddress(query.From().IP()) {
+			return nil, nil
+		}
+		return responseForAnswers(records, answers, unicastResponseOptions(query)...), nil
+	}
+
+	// In any case where there may be multiple responses, such as queries where the answer is a member of
+	// a shared resource record set, each responder SHOULD delay its response by a random amount of time
+	// selected with uniform random distribution in the range 20-120 ms.
+	isUnicastResponse := query.IsQueryWithUnicastResponse() && server.isMulticastRecentlyOn(query.To(), answers)
+	if len(uniqueRecords(answers)) == len(answers) {
+		if isUnicastResponse {
+			return responseForAnswers(records, answers, unicastResponseOptions(query)...), nil
+		}
+		return server.multicastResponseForAnswers(query, records, answers), nil
+	}
+	delay := MinResponseDelay + rand.N(MaxResponseDelay-MinResponseDelay)
+
+	if isUnicastResponse {
+		server.sleep(delay)
+		return responseForAnswers(server.interfaceRecords(query.To()), answers, unicastResponseOptions(query)...), nil
+	}
+
+	if query.To() == nil {
+		server.sleep(delay)
+		return server.multicastResponseForAnswers(query, server.interfaceRecords(query.To()), answers), nil
+	}
+
+	key := query.To().String()
+	if !server.aggregateAnswers(key, answers) {
+		return nil, nil
+	}
+	server.sleep(delay)
+	return server.multicastResponseForAnswers(query, server.interfaceRecords(query.To()), server.flushAnswers(key)), nil
+}
+
+// isMulticastRecentlyOn returns true if the specified answers have been multicast recently on the interface which has the specified local address.
+// If the interface is unknown, the answers are treated as multicast recently, and the querier receives the unicast response as requested.
+// RFC 6762: 5.4. Questions Requesting Unicast Responses
+func (server *Server) isMulticastRecentlyOn(to dns.Addr, answers ResourceRecordSet) bool {
+	if to == nil {
+		return true
+	}
+	return server.isMulticastRecently(to.String(), answers)
+}
+
+// isProbeQuery returns true if the specified query is a probe query which has the proposed records in the authority section.
+// RFC 6762: 8.1. Probing
+func isProbeQuery(query Message) bool {
+	return 0 < len(query.NameServers())
+}
+
+// multicastResponseForAnswers returns a multicast response message with the specified answers and the additional records
+// except the records which have been multicast recently on the interface which received the specified query,
+// or nil if all answers have been multicast recently.
+// RFC 6762: 6. Responding
+func (server *Server) multicastResponseForAnswers(query Message, records ResourceRecordSet, answers ResourceRecordSet) Message {
+	if query.To() == nil {
+		return responseForAnswers(records, answers)
+	}
+
+	key := query.To().String()
+	interval := MulticastInterval
+	if isProbeQuery(query) {
+		interval = ProbeDefenseInterval
+	}
+	answers = server.limitMulticastRecords(key, answers, interval)
+	if len(answers) == 0 {
+		return nil
+	}
+	additions := server.limitMulticastRecords(key, lookupAdditionalRecords(records, answers), interval)
+
+	return dns.NewResponseMessage(
+		dns.WithMessageAnswers(answers...),
+		dns.WithMessageAdditions(additions...),
+	)
+}
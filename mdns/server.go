@@ -15,7 +15,12 @@
 package mdns
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/cybergarage/go-mdns/mdns/dns"
 	"github.com/cybergarage/go-mdns/mdns/transport"
@@ -27,17 +32,36 @@ type Server struct {
 	*transport.MessageManager
 	*serviceSet
 	*msgHandler
+	*proberSet
+	*announcerSet
+	*answerAggregator
+	*truncatedQuerySet
+	*multicastLimiter
+	*hostAddressCache
+	host                string
+	registrationHandler RegistrationHandler
+	sleep               func(time.Duration)
 }
 
 // NewServer returns a new server instance.
 func NewServer() *Server {
 	server := &Server{
-		Mutex:          sync.Mutex{},
-		MessageManager: transport.NewMessageManager(),
-		serviceSet:     newServiceSet(),
-		msgHandler:     newMessageHandler(),
+		Mutex:               sync.Mutex{},
+		MessageManager:      transport.NewMessageManager(),
+		serviceSet:          newServiceSet(),
+		msgHandler:          newMessageHandler(),
+		proberSet:           newProberSet(),
+		announcerSet:        newAnnouncerSet(),
+		answerAggregator:    newAnswerAggregator(),
+		truncatedQuerySet:   newTruncatedQuerySet(),
+		multicastLimiter:    newMulticastLimiter(),
+		hostAddressCache:    newHostAddressCache(),
+		host:                defaultHostName(),
+		registrationHandler: nil,
+		sleep:               time.Sleep,
 	}
 	server.SetMessageProcessor(server.MessageReceived)
+	server.addressesChanged = server.hostAddressesChanged
 	return server
 }
 
@@ -46,12 +70,41 @@ func (server *Server) Start() error {
 	if err := server.Stop(); err != nil {
 		return err
 	}
-	return server.MessageManager.Start()
+	if err := server.MessageManager.Start(); err != nil {
+		return err
+	}
+	server.reloadHostAddresses()
+	return server.probeServices()
 }
 
-// Stop stops the server instance.
+// Stop stops the server instance after sending goodbye packets for all registered services.
+// The running announcements are canceled before the goodbye packets, so that no announcement follows them.
+// RFC 6762: 10.1. Goodbye Packets
 func (server *Server) Stop() error {
-	return server.MessageManager.Stop()
+	var errs error
+	server.stopAnnouncers()
+	if server.IsRunning() {
+		server.Lock()
+		services := slices.Clone(server.Services())
+		server.Unlock()
+		// The addresses are reloaded without notifying the change, so that the goodbye packets have the current addresses
+		// and no announcement follows them.
+		server.reloadHostAddresses()
+		goodbyeRecords, err := server.sendHostGoodbye()
+		errs = err
+		for _, service := range services {
+			records, err := server.serviceRecords(service)
+			if err != nil {
+				continue
+			}
+			records = slices.DeleteFunc(records, func(record ResourceRecord) bool {
+				return slices.ContainsFunc(goodbyeRecords, record.Equal)
+			})
+			goodbyeRecords = append(goodbyeRecords, records...)
+			errs = errors.Join(errs, server.sendGoodbye(records))
+		}
+	}
+	return errors.Join(errs, server.MessageManager.Stop())
 }
 
 // Restart restarts the server instance.
@@ -62,12 +115,88 @@ func (server *Server) Restart() error {
 	return server.Start()
 }
 
+// RegisterService registers the specified service to publish.
+// If the server is running, RegisterService probes the unique names of the service before publishing,
+// and renames the service automatically if any other host already uses the names.
+// The new name is notified to the handler set by SetRegistrationHandler.
+// RFC 6762: 8.1. Probing
+// RFC 6762: 9. Conflict Resolution
+func (server *Server) RegisterService(service Service) error {
+	return server.claimService(service)
+}
+
+// UpdateService replaces the registered service which has the same name with the specified service,
+// and announces the updated records, such as new TXT or address records, again.
+// RFC 6762: 8.4. Updating
+func (server *Server) UpdateService(service Service) error {
+	if _, err := server.serviceRecords(service); err != nil {
+		return err
+	}
+
+	server.Lock()
+	idx := slices.IndexFunc(server.Services(), func(registeredService Service) bool {
+		return strings.EqualFold(registeredService.Name(), service.Name())
+	})
+	if idx < 0 {
+		server.Unlock()
+		return fmt.Errorf("service (%s) is %w", service.Name(), ErrNotFound)
+	}
+	oldService := server.Services()[idx]
+	server.RemoveService(oldService)
+	server.AddService(service)
+	server.Unlock()
+
+	server.cancelAnnouncers(oldService)
+	server.announceService(service)
+
+	// RFC 6762: 10.1. Goodbye Packets
+	// The records which are no longer valid, such as the removed addresses, are withdrawn with goodbye packets.
+	oldRecords, err := server.serviceRecords(oldService)
+	if err != nil {
+		return err
+	}
+	return server.sendGoodbye(server.unpublishedRecords(oldRecords))
+}
+
+// UnregisterService unregisters the specified service, and sends goodbye packets for the service records if the server is running.
+// RFC 6762: 10.1. Goodbye Packets
+func (server *Server) UnregisterService(service Service) error {
+	server.Lock()
+	removed := server.RemoveService(service)
+	server.Unlock()
+	if !removed {
+		return fmt.Errorf("service (%s) is %w", service.Name(), ErrNotFound)
+	}
+	server.cancelAnnouncers(service)
+	records, err := server.serviceRecords(service)
+	if err != nil {
+		return err
+	}
+	return server.sendGoodbye(server.unpublishedRecords(records))
+}
+
+// MessageReceived handles the specified message, and returns a response message to answer the query if the server has any matching records.
 func (server *Server) MessageReceived(msg dns.Message) (dns.Message, error) {
 	if msg.IsResponse() {
+		if msg.To() != nil {
+			server.suppressAnswers(msg.To().String(), msg.Answers())
+		}
+		records := server.claimedRecords(msg.ResourceRecordSet())
+		server.proberSet.responseReceived(records)
+		server.resolveConflicts(records)
 		return nil, nil
 	}
 
+	if 0 < len(msg.NameServers()) {
+		server.proberSet.probeReceived(msg.NameServers())
+	}
+
 	server.processMessageHandlers(msg)
 
-	return nil, nil
+	msg = server.receiveTruncatedQuery(msg)
+	if msg == nil {
+		return nil, nil
+	}
+
+	return server.responseForQuery(msg)
 }
@@ -0,0 +1,1165 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+func newTestService(t *testing.T) Service {
+	t.Helper()
+	service, err := NewService(
+		WithServiceName("Test Printer._http._tcp"),
+		WithServiceDomain(LocalDomain),
+		WithServiceHost("printer.local"),
+		WithServicePort(8080),
+		WithServiceAddresses(net.IPv4(192, 0, 2, 10), net.ParseIP("2001:db8::10")),
+		WithServiceAttribute("path", "/index.html"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+func newTestQuery(name string, typ dns.Type) Message {
+	return dns.NewRequestMessage(
+		dns.WithMessageQuestions(
+			dns.NewQuestion(
+				dns.WithQuestionName(name),
+				dns.WithQuestionType(typ),
+				dns.WithQuestionClass(dns.IN),
+			),
+		),
+	)
+}
+
+// testSleeper represents a sleep function of the server which blocks until the test resumes it.
+type testSleeper struct {
+	delays chan time.Duration
+	resume chan struct{}
+}
+
+// newTestSleeper returns a new test sleeper, and sets it to the specified server.
+func newTestSleeper(server *Server) *testSleeper {
+	sleeper := &testSleeper{
+		delays: make(chan time.Duration, 16),
+		resume: make(chan struct{}, 16),
+	}
+	server.sleep = sleeper.sleep
+	return sleeper
+}
+
+func (sleeper *testSleeper) sleep(delay time.Duration) {
+	sleeper.delays <- delay
+	<-sleeper.resume
+}
+
+// waitDelay waits until the server sleeps, and checks the delay is in the specified range.
+func (sleeper *testSleeper) waitDelay(t *testing.T, minDelay time.Duration, maxDelay time.Duration) {
+	t.Helper()
+	select {
+	case delay := <-sleeper.delays:
+		if delay < minDelay || maxDelay < delay {
+			t.Errorf("delay %s is not in %s-%s", delay, minDelay, maxDelay)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("server does not delay")
+	}
+}
+
+// resumeDelay resumes the sleeping server.
+func (sleeper *testSleeper) resumeDelay() {
+	sleeper.resume <- struct{}{}
+}
+
+// testClock represents a clock which advances only when the test advances it.
+type testClock struct {
+	sync.Mutex
+	now time.Time
+}
+
+// newTestClock returns a new test clock.
+func newTestClock() *testClock {
+	return &testClock{
+		Mutex: sync.Mutex{},
+		now:   time.Now(),
+	}
+}
+
+// Now returns the current time of the clock.
+func (clock *testClock) Now() time.Time {
+	clock.Lock()
+	defer clock.Unlock()
+	return clock.now
+}
+
+// Advance advances the clock by the specified duration.
+func (clock *testClock) Advance(d time.Duration) {
+	clock.Lock()
+	defer clock.Unlock()
+	clock.now = clock.now.Add(d)
+}
+
+func TestServerResponder(t *testing.T) {
+	server := NewServer()
+	service := newTestService(t)
+	if err := server.RegisterService(service); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("PTR", func(t *testing.T) {
+		res, err := server.MessageReceived(newTestQuery("_http._tcp.local", dns.PTR))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res == nil {
+			t.Fatal("no response")
+		}
+
+		resMsg, err := dns.NewMessageWithBytes(res.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !resMsg.IsResponse() {
+			t.Errorf("not response message")
+		}
+		if len(resMsg.Answers()) != 1 {
+			t.Errorf("answers %d != %d", len(resMsg.Answers()), 1)
+		}
+		if len(resMsg.Additions()) != 4 {
+			t.Errorf("additions %d != %d", len(resMsg.Additions()), 4)
+		}
+
+		resService, err := NewService(WithServiceMessage(resMsg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resService.Name() != service.Name() {
+			t.Errorf("%s != %s", resService.Name(), service.Name())
+		}
+		if resService.Host() != service.Host() {
+			t.Errorf("%s != %s", resService.Host(), service.Host())
+		}
+		if resService.Port() != service.Port() {
+			t.Errorf("%d != %d", resService.Port(), service.Port())
+		}
+		if len(resService.Addresses()) != len(service.Addresses()) {
+			t.Errorf("%v != %v", resService.Addresses(), service.Addresses())
+		}
+		attr, ok := resService.LookupResourceAttribute("path")
+		if !ok || attr.Value() != "/index.html" {
+			t.Errorf("TXT attribute (path) not found")
+		}
+	})
+
+	t.Run("A", func(t *testing.T) {
+		res, err := server.MessageReceived(newTestQuery("printer.local", dns.A))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res == nil || len(res.Answers()) != 1 {
+			t.Fatalf("invalid response: %v", res)
+		}
+	})
+
+	t.Run("KnownAnswer", func(t *testing.T) {
+		newKnownAnswerQuery := func(ttl uint) Message {
+			ptr := dns.NewPTRRecord().SetDomainName("Test Printer._http._tcp.local")
+			ptr.SetName("_http._tcp.local")
+			ptr.SetTTL(ttl)
+			return dns.NewRequestMessage(
+				dns.WithMessageQuestions(newTestQuery("_http._tcp.local", dns.PTR).Questions()...),
+				dns.WithMessageAnswers(ptr),
+			)
+		}
+
+		// RFC 6762: 7.1. Known-Answer Suppression
+		res, err := server.MessageReceived(newKnownAnswerQuery(DefaultRecordTTL / 2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != nil {
+			t.Errorf("known answer should be suppressed:\n%s", res.String())
+		}
+
+		res, err = server.MessageReceived(newKnownAnswerQuery(DefaultRecordTTL/2 - 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res == nil || len(res.Answers()) != 1 {
+			t.Errorf("known answer with less than half TTL should be answered: %v", res)
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		res, err := server.MessageReceived(newTestQuery("_ipp._tcp.local", dns.PTR))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != nil {
+			t.Errorf("unexpected response:\n%s", res.String())
+		}
+	})
+
+	t.Run("Negative", func(t *testing.T) {
+		// RFC 6762: 6.1. Negative Responses
+		res, err := server.MessageReceived(newTestQuery("printer.local", dns.TXT))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res == nil || len(res.Answers()) != 1 {
+			t.Fatalf("invalid response: %v", res)
+		}
+		nsec, ok := res.Answers()[0].(dns.NSECRecord)
+		if !ok {
+			t.Fatalf("invalid negative answer: %s", res.Answers()[0].Type().String())
+		}
+		if !nsec.HasType(dns.A) || !nsec.HasType(dns.AAAA) || nsec.HasType(dns.TXT) {
+			t.Errorf("invalid negative answer: %s", nsec.Content())
+		}
+
+		// Shared names are not owned by this host.
+		res, err = server.MessageReceived(newTestQuery("_http._tcp.local", dns.TXT))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != nil {
+			t.Errorf("unexpected response:\n%s", res.String())
+		}
+	})
+
+	if err := server.UnregisterService(service); err != nil {
+		t.Error(err)
+	}
+	if err := server.UnregisterService(service); err == nil {
+		t.Errorf("unregistered service should not be found")
+	}
+}
+
+func TestServerProber(t *testing.T) {
+	service := newTestService(t)
+	records, err := newServiceRecords(service, defaultHostName())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := newProber(records)
+
+	t.Run("Probe", func(t *testing.T) {
+		msg, err := dns.NewMessageWithBytes(p.probeMessage(true).Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.IsResponse() {
+			t.Errorf("probe is not query message")
+		}
+		// Instance name and host name
+		if len(msg.Questions()) != 2 {
+			t.Errorf("questions %d != %d", len(msg.Questions()), 2)
+		}
+		for _, q := range msg.Questions() {
+			if q.Type() != dns.ANY || !q.IsUnicastResponse() {
+				t.Errorf("invalid probe question: %s %s", q.Name(), q.Type().String())
+			}
+		}
+		// SRV, TXT, A and AAAA records
+		if len(msg.NameServers()) != 4 {
+			t.Errorf("authorities %d != %d", len(msg.NameServers()), 4)
+		}
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		if record, ok := conflictingRecord(p.records, records); ok {
+			t.Errorf("identical record should not conflict: %s", record.Content())
+		}
+
+		srv := dns.NewSRVRecord().SetPort(8081).SetTarget("printer.local")
+		srv.SetName("Test Printer._http._tcp.local")
+		srv.SetTTL(DefaultRecordTTL)
+		srv.SetCacheFlush(true)
+		res := dns.NewResponseMessage(dns.WithMessageAnswers(srv))
+
+		server := NewServer()
+		server.addProber(p)
+		defer server.removeProber(p)
+		if _, err := server.MessageReceived(res); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case record := <-p.conflictCh:
+			if record.Type() != dns.SRV {
+				t.Errorf("invalid conflicting record: %s", record.Content())
+			}
+		default:
+			t.Errorf("conflict not detected")
+		}
+	})
+
+	t.Run("ConflictAnyType", func(t *testing.T) {
+		// A record with the probed name conflicts in probing even if its type differs.
+		a := dns.NewARecordWithAddress(net.ParseIP("192.168.1.100"))
+		a.SetName("Test Printer._http._tcp.local")
+		a.SetTTL(DefaultRecordTTL)
+		a.SetCacheFlush(true)
+		if _, ok := conflictingRecord(p.records, ResourceRecordSet{a}); ok {
+			t.Errorf("record of other type should not conflict in conflict resolution")
+		}
+
+		server := NewServer()
+		server.addProber(p)
+		defer server.removeProber(p)
+		if _, err := server.MessageReceived(dns.NewResponseMessage(dns.WithMessageAnswers(a))); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case record := <-p.conflictCh:
+			if record.Type() != dns.A {
+				t.Errorf("invalid conflicting record: %s", record.Content())
+			}
+		default:
+			t.Errorf("conflict not detected")
+		}
+	})
+
+	t.Run("Tiebreak", func(t *testing.T) {
+		server := NewServer()
+		server.addProber(p)
+		defer server.removeProber(p)
+
+		probe := func(port uint) {
+			srv := dns.NewSRVRecord().SetPort(port).SetTarget("printer.local")
+			srv.SetName("Test Printer._http._tcp.local")
+			srv.SetCacheFlush(true)
+			query := dns.NewRequestMessage(
+				dns.WithMessageQuestions(dns.NewQuestion(
+					dns.WithQuestionName("Test Printer._http._tcp.local"),
+					dns.WithQuestionType(dns.ANY),
+					dns.WithQuestionClass(dns.IN),
+				)),
+				dns.WithMessageNameServers(srv, records.LookupTXTRecordSet()[0]),
+			)
+			if _, err := server.MessageReceived(query); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// The proposed records are lexicographically later than the other host's records.
+		probe(80)
+		select {
+		case <-p.deferCh:
+			t.Errorf("winner should not defer")
+		default:
+		}
+
+		// The proposed records are lexicographically earlier than the other host's records.
+		probe(9000)
+		select {
+		case <-p.deferCh:
+		default:
+			t.Errorf("loser should defer")
+		}
+	})
+}
+
+func TestServerRename(t *testing.T) {
+	service := newTestService(t)
+	records, err := newServiceRecords(service, defaultHostName())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		conflict ResourceRecord
+		name     string
+		host     string
+	}{
+		{records.LookupSRVRecordSet()[0], "Test Printer (2)._http._tcp", "printer.local"},
+		{records.LookupARecordSet()[0], "Test Printer._http._tcp", "printer-2.local"},
+	}
+
+	for _, test := range tests {
+		renamedService, err := renameService(service, defaultHostName(), test.conflict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if renamedService.Name() != test.name {
+			t.Errorf("%s != %s", renamedService.Name(), test.name)
+		}
+		if renamedService.Host() != test.host {
+			t.Errorf("%s != %s", renamedService.Host(), test.host)
+		}
+		if renamedService.Port() != service.Port() || len(renamedService.ResourceAttributes()) != 1 {
+			t.Errorf("%s != %s", renamedService.String(), service.String())
+		}
+		nextService, err := renameService(renamedService, defaultHostName(), test.conflict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nextService.Name() == renamedService.Name() && nextService.Host() == renamedService.Host() {
+			t.Errorf("%s is not renamed", nextService.String())
+		}
+	}
+
+	for _, name := range []string{"Printer", "Printer (2)", "Printer (9)"} {
+		expected := map[string]string{"Printer": "Printer (2)", "Printer (2)": "Printer (3)", "Printer (9)": "Printer (10)"}[name]
+		if next := nextName(name, instanceNumberRegex, "%s (%d)"); next != expected {
+			t.Errorf("%s != %s", next, expected)
+		}
+	}
+}
+
+func TestServerAnnouncerCancel(t *testing.T) {
+	server := NewServer()
+	service := newTestService(t)
+	serviceAnnouncer := newAnnouncer(service)
+	hostAnnouncer := newAnnouncer(nil)
+	server.addAnnouncer(serviceAnnouncer)
+	server.addAnnouncer(hostAnnouncer)
+
+	// UnregisterService and UpdateService cancel only the announcer of the service.
+	server.cancelAnnouncers(newTestService(t))
+	if !serviceAnnouncer.isCanceled() {
+		t.Errorf("service announcer is not canceled")
+	}
+	if hostAnnouncer.isCanceled() {
+		t.Errorf("host announcer is canceled")
+	}
+
+	// Stop cancels all the announcers, and waits until they finish.
+	finished := make(chan struct{})
+	go func() {
+		server.stopAnnouncers()
+		close(finished)
+	}()
+	select {
+	case <-hostAnnouncer.cancelCh:
+	case <-time.After(time.Second):
+		t.Fatalf("host announcer is not canceled")
+	}
+	server.removeAnnouncer(serviceAnnouncer)
+	select {
+	case <-finished:
+		t.Fatalf("announcers are not waited")
+	default:
+	}
+	server.removeAnnouncer(hostAnnouncer)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatalf("announcers are not stopped")
+	}
+}
+
+func TestServerOwnMulticastConflict(t *testing.T) {
+	server := NewServer()
+	service := newTestService(t)
+	if err := server.RegisterService(service); err != nil {
+		t.Fatal(err)
+	}
+
+	// The outdated record which this server multicast before the update is not a conflict when it is looped back.
+	txt := dns.NewTXTRecord().SetStrings([]string{"path=/old.html"})
+	txt.SetName("Test Printer._http._tcp.local")
+	txt.SetTTL(DefaultRecordTTL)
+	txt.SetCacheFlush(true)
+	server.multicastRecords("", ResourceRecordSet{txt})
+	if _, err := server.MessageReceived(dns.NewResponseMessage(dns.WithMessageAnswers(txt))); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(server.Services(), service) {
+		t.Errorf("service is removed by the own record: %v", server.Services())
+	}
+}
+
+func TestServerResponseAggregation(t *testing.T) {
+	server := NewServer()
+	service := newTestService(t)
+	if err := server.RegisterService(service); err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewService(
+		WithServiceName("Test Printer._ipp._tcp"),
+		WithServiceHost("printer.local"),
+		WithServicePort(631),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterService(other); err != nil {
+		t.Fatal(err)
+	}
+
+	// RFC 6762: 6. Responding
+	// Unique answers are returned immediately.
+	sleeper := newTestSleeper(server)
+	res, err := server.MessageReceived(newTestQuery("printer.local", dns.A))
+	if err != nil || res == nil {
+		t.Fatalf("no response: %v", err)
+	}
+	if n := len(sleeper.delays); n != 0 {
+		t.Errorf("unique answer is delayed: %d", n)
+	}
+
+	// Shared answers to near-simultaneous queries on the same interface are aggregated into a single response.
+	to, err := dns.NewAddrFromString("224.0.0.251:5353")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newQuery := func(name string) Message {
+		return dns.NewRequestMessage(
+			dns.WithMessageQuestions(newTestQuery(name, dns.PTR).Questions()...),
+			dns.WithMessageTo(to),
+		)
+	}
+	responses := make([]Message, 2)
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		responses[0], _ = server.MessageReceived(newQuery("_http._tcp.local"))
+	})
+	sleeper.waitDelay(t, MinResponseDelay, MaxResponseDelay)
+	responses[1], _ = server.MessageReceived(newQuery("_ipp._tcp.local"))
+	sleeper.resumeDelay()
+	wg.Wait()
+	responses = slices.DeleteFunc(responses, func(res Message) bool { return res == nil })
+	if len(responses) != 1 {
+		t.Fatalf("responses %d != %d", len(responses), 1)
+	}
+	if len(responses[0].Answers()) != 2 {
+		t.Errorf("answers %d != %d", len(responses[0].Answers()), 2)
+	}
+}
+
+func TestServerUnicastResponse(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterService(newTestService(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	newQuery := func(from string, transport dns.Transport) Message {
+		addr, err := dns.NewAddrFromString(from, dns.WithAddrTransport(transport))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dns.NewRequestMessage(
+			dns.WithMessageID(0x1234),
+			dns.WithMessageQuestions(newTestQuery("Test Printer._http._tcp.local", dns.SRV).Questions()...),
+			dns.WithMessageFrom(addr),
+		)
+	}
+
+	t.Run("Legacy", func(t *testing.T) {
+		// RFC 6762: 6.7. Legacy Unicast Responses
+		res, err := server.MessageReceived(newQuery("192.0.2.100:49152", dns.TransportMulticast))
+		if err != nil || res == nil {
+			t.Fatalf("no response: %v", err)
+		}
+		res, err = dns.NewMessageWithBytes(res.Bytes(), dns.WithMessageTo(res.To()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.ID() != 0x1234 {
+			t.Errorf("%X != %X", res.ID(), 0x1234)
+		}
+		if len(res.Questions()) != 1 {
+			t.Errorf("questions %d != %d", len(res.Questions()), 1)
+		}
+		if res.To() == nil || res.To().Port() != 49152 {
+			t.Errorf("invalid destination: %v", res.To())
+		}
+		for _, record := range res.ResourceRecordSet() {
+			if LegacyUnicastTTL < record.TTL() || record.CacheFlush() {
+				t.Errorf("invalid legacy record: %s (ttl=%d, cache-flush=%t)", record.Name(), record.TTL(), record.CacheFlush())
+			}
+		}
+	})
+
+	t.Run("Direct", func(t *testing.T) {
+		// RFC 6762: 5.5. Direct Unicast Queries to Port 5353
+		res, err := server.MessageReceived(newQuery("127.0.0.1:5353", dns.TransportUDP))
+		if err != nil || res == nil {
+			t.Fatalf("no response: %v", err)
+		}
+		if res.ID() != 0x1234 || res.To() == nil || len(res.Questions()) != 0 {
+			t.Errorf("invalid direct unicast response: %v", res)
+		}
+		res, err = server.MessageReceived(newQuery("198.51.100.1:5353", dns.TransportUDP))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != nil {
+			t.Errorf("query from off-link address should be ignored")
+		}
+	})
+
+	t.Run("Multicast", func(t *testing.T) {
+		res, err := server.MessageReceived(newQuery("192.0.2.100:5353", dns.TransportMulticast))
+		if err != nil || res == nil {
+			t.Fatalf("no response: %v", err)
+		}
+		if res.To() != nil {
+			t.Errorf("multicast response should not have destination: %v", res.To())
+		}
+	})
+}
+
+func TestServerTruncatedQuery(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterService(newTestService(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	from, err := dns.NewAddrFromString("192.0.2.100:5353", dns.WithAddrTransport(dns.TransportMulticast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptr := dns.NewPTRRecord().SetDomainName("Test Printer._http._tcp.local")
+	ptr.SetName("_http._tcp.local")
+	ptr.SetTTL(DefaultRecordTTL)
+
+	truncatedQuery := dns.NewRequestMessage(
+		dns.WithMessageQuestions(newTestQuery("_http._tcp.local", dns.PTR).Questions()...),
+		dns.WithMessageTC(true),
+		dns.WithMessageFrom(from),
+	)
+
+	sleeper := newTestSleeper(server)
+
+	t.Run("Timeout", func(t *testing.T) {
+		// RFC 6762: 7.2. Multipacket Known-Answer Suppression
+		resCh := make(chan Message)
+		go func() {
+			res, _ := server.MessageReceived(truncatedQuery)
+			resCh <- res
+		}()
+		sleeper.waitDelay(t, MinTruncatedQueryDelay, MaxTruncatedQueryDelay)
+		sleeper.resumeDelay()
+		sleeper.waitDelay(t, MinResponseDelay, MaxResponseDelay)
+		sleeper.resumeDelay()
+		if res := <-resCh; res == nil {
+			t.Fatalf("no response")
+		}
+	})
+
+	t.Run("KnownAnswer", func(t *testing.T) {
+		knownAnswerQuery := dns.NewRequestMessage(
+			dns.WithMessageAnswers(ptr),
+			dns.WithMessageFrom(from),
+		)
+		responses := make([]Message, 2)
+		var wg sync.WaitGroup
+		wg.Go(func() {
+			responses[0], _ = server.MessageReceived(truncatedQuery)
+		})
+		sleeper.waitDelay(t, MinTruncatedQueryDelay, MaxTruncatedQueryDelay)
+		responses[1], _ = server.MessageReceived(knownAnswerQuery)
+		sleeper.resumeDelay()
+		wg.Wait()
+		for n, res := range responses {
+			if res != nil {
+				t.Errorf("[%d] known answer in the following packet should be suppressed:\n%s", n, res.String())
+			}
+		}
+	})
+}
+
+func TestServerMulticastRateLimit(t *testing.T) {
+	server := NewServer()
+	clock := newTestClock()
+	server.multicastLimiter.now = clock.Now
+	service := newTestService(t)
+	if err := server.RegisterService(service); err != nil {
+		t.Fatal(err)
+	}
+
+	to, err := dns.NewAddrFromString("224.0.0.251:5353")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newQuery := func(opts ...dns.MessageOption) Message {
+		opts = append(opts,
+			dns.WithMessageQuestions(newTestQuery("printer.local", dns.A).Questions()...),
+			dns.WithMessageTo(to),
+		)
+		return dns.NewRequestMessage(opts...)
+	}
+
+	// RFC 6762: 6. Responding
+	// A record is not multicast again on the same interface within one second.
+	res, err := server.MessageReceived(newQuery())
+	if err != nil || res == nil {
+		t.Fatalf("no response: %v", err)
+	}
+	res, err = server.MessageReceived(newQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != nil {
+		t.Errorf("record is multicast again within %s", MulticastInterval)
+	}
+
+	// The only exception is answering probe queries, which may be multicast at 250 ms intervals.
+	a := dns.NewARecordWithAddress(net.ParseIP("192.0.2.20"))
+	a.SetName("printer.local")
+	a.SetCacheFlush(true)
+	probe := newQuery(dns.WithMessageNameServers(a))
+	clock.Advance(ProbeDefenseInterval)
+	res, err = server.MessageReceived(probe)
+	if err != nil || res == nil {
+		t.Fatalf("no response to probe: %v", err)
+	}
+	res, err = server.MessageReceived(probe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != nil {
+		t.Errorf("record is multicast again within %s", ProbeDefenseInterval)
+	}
+
+	clock.Advance(MulticastInterval)
+	res, err = server.MessageReceived(newQuery())
+	if err != nil || res == nil {
+		t.Fatalf("no response after %s: %v", MulticastInterval, err)
+	}
+
+	// The records announced on all interfaces are not multicast again on any interface within one second.
+	clock.Advance(MulticastInterval)
+	records, err := server.serviceRecords(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.multicastRecords("", records.LookupRecordSetByName("printer.local"))
+	res, err = server.MessageReceived(newQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != nil {
+		t.Errorf("announced record is multicast again within %s", MulticastInterval)
+	}
+}
+
+func TestServerNegativeAdditions(t *testing.T) {
+	server := NewServer()
+	service, err := NewService(
+		WithServiceName("Test Printer._ipp._tcp"),
+		WithServiceHost("printer.local"),
+		WithServicePort(631),
+		WithServiceAddresses(net.ParseIP("192.0.2.10")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterService(service); err != nil {
+		t.Fatal(err)
+	}
+
+	// RFC 6762: 6.1. Negative Responses
+	// The host which has only IPv4 addresses asserts the nonexistence of AAAA records in the additional section.
+	res, err := server.MessageReceived(newTestQuery("Test Printer._ipp._tcp.local", dns.SRV))
+	if err != nil || res == nil {
+		t.Fatalf("no response: %v", err)
+	}
+	res, err = dns.NewMessageWithBytes(res.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range res.Additions() {
+		nsec, ok := record.(dns.NSECRecord)
+		if !ok {
+			continue
+		}
+		if !nsec.IsName("printer.local") || nsec.HasType(dns.AAAA) || !nsec.HasType(dns.A) {
+			t.Errorf("invalid negative record: %s %s", nsec.Name(), nsec.Content())
+		}
+		return
+	}
+	t.Errorf("negative record not found")
+}
+
+func TestServerHost(t *testing.T) {
+	t.Run("Sanitize", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			expected string
+		}{
+			{"myhost", "myhost"},
+			{"myhost.example.com", "myhost"},
+			{"My Host_01", "My-Host-01"},
+			{"-myhost-", "myhost"},
+		}
+		for _, test := range tests {
+			if label := sanitizeHostLabel(test.name); label != test.expected {
+				t.Errorf("%s != %s", label, test.expected)
+			}
+		}
+	})
+
+	t.Run("ReverseName", func(t *testing.T) {
+		tests := []struct {
+			ip       string
+			expected string
+		}{
+			{"192.0.2.10", "10.2.0.192.in-addr.arpa"},
+			{"2001:db8::10", "0.1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"},
+		}
+		for _, test := range tests {
+			if name := reverseName(net.ParseIP(test.ip)); name != test.expected {
+				t.Errorf("%s != %s", name, test.expected)
+			}
+		}
+	})
+
+	server := NewServer()
+	if !strings.HasSuffix(server.Host(), ".local") {
+		t.Errorf("invalid default host name: %s", server.Host())
+	}
+	server.SetHost("myhost")
+	if server.Host() != "myhost.local" {
+		t.Errorf("%s != %s", server.Host(), "myhost.local")
+	}
+
+	addrs := server.hostAddresses(nil)
+	if len(addrs) == 0 {
+		t.Skip("available address not found")
+	}
+
+	// RFC 6762: 14. Considerations for Multiple Interfaces
+	// The address records are answered only with the addresses of the interface which received the query.
+	to, err := dns.NewAddrFromString(net.JoinHostPort(addrs[0].String(), "5353"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ifaddrs := server.hostAddresses(to.IP())
+	for _, typ := range []dns.Type{dns.A, dns.AAAA} {
+		res, err := server.MessageReceived(newTestQuery("myhost.local", typ))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res == nil {
+			continue
+		}
+		query := dns.NewRequestMessage(
+			dns.WithMessageQuestions(newTestQuery("myhost.local", typ).Questions()...),
+			dns.WithMessageTo(to),
+		)
+		res, err = server.MessageReceived(query)
+		if err != nil || res == nil {
+			t.Fatalf("no response: %v", err)
+		}
+		for _, record := range res.Answers() {
+			var ip net.IP
+			switch record := record.(type) {
+			case dns.ARecord:
+				ip = record.Address()
+			case dns.AAAARecord:
+				ip = record.Address()
+			default:
+				continue
+			}
+			if !slices.ContainsFunc(ifaddrs, ip.Equal) {
+				t.Errorf("address (%s) is not valid on the interface", ip)
+			}
+		}
+	}
+
+	// The reverse mapping records point to the host name.
+	res, err := server.MessageReceived(newTestQuery(reverseName(addrs[0]), dns.PTR))
+	if err != nil || res == nil {
+		t.Fatalf("no response: %v", err)
+	}
+	ptr, ok := res.Answers()[0].(dns.PTRRecord)
+	if !ok || ptr.DomainName() != "myhost.local" {
+		t.Errorf("invalid reverse mapping record: %s", res.Answers()[0].Content())
+	}
+
+	// The SRV record of the service which has no host name targets the host name.
+	service, err := NewService(
+		WithServiceName("Test Printer._http._tcp"),
+		WithServicePort(8080),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterService(service); err != nil {
+		t.Fatal(err)
+	}
+	res, err = server.MessageReceived(newTestQuery("Test Printer._http._tcp.local", dns.SRV))
+	if err != nil || res == nil {
+		t.Fatalf("no response: %v", err)
+	}
+	srv, ok := res.Answers()[0].(dns.SRVRecord)
+	if !ok || srv.Target() != "myhost.local" {
+		t.Errorf("invalid SRV record: %s", res.Answers()[0].Content())
+	}
+	if len(res.Additions().LookupRecordSetByName("myhost.local")) == 0 {
+		t.Errorf("address records of the host not found")
+	}
+}
+
+func TestServerHostAddressRefresh(t *testing.T) {
+	server := NewServer()
+	clock := newTestClock()
+	server.hostAddressCache.now = clock.Now
+	server.hostAddresses(nil)
+
+	// The interface addresses are not reloaded for each query until the refresh interval elapses.
+	addr := net.IPv4(192, 0, 2, 99)
+	server.hostAddressCache.ifaddrs = [][]net.IP{{addr}}
+	if addrs := server.hostAddresses(nil); len(addrs) != 1 || !addrs[0].Equal(addr) {
+		t.Errorf("cached addresses are not used: %v", addrs)
+	}
+	// RFC 6762: 8.4. Updating
+	// The change of the reloaded addresses is notified with the previous addresses to announce the host records again.
+	changedAddrs := []net.IP{}
+	server.addressesChanged = func(oldAddrs []net.IP) {
+		changedAddrs = oldAddrs
+	}
+	clock.Advance(HostAddressRefreshInterval)
+	if addrs := server.hostAddresses(nil); slices.ContainsFunc(addrs, addr.Equal) {
+		t.Errorf("addresses are not reloaded: %v", addrs)
+	}
+	if len(changedAddrs) != 1 || !changedAddrs[0].Equal(addr) {
+		t.Errorf("address change is not notified: %v", changedAddrs)
+	}
+}
+
+func TestServerConflictThrottle(t *testing.T) {
+	server := NewServer()
+	sleeper := newTestSleeper(server)
+
+	// RFC 6762: 8.1. Probing
+	// If fifteen conflicts occur within any ten-second period, the host waits before each successive probe.
+	conflicts := []time.Time{}
+	for range ConflictLimit - 1 {
+		conflicts = server.throttleConflicts(conflicts)
+	}
+	select {
+	case delay := <-sleeper.delays:
+		t.Fatalf("probe is delayed %s before %d conflicts", delay, ConflictLimit)
+	default:
+	}
+	done := make(chan struct{})
+	go func() {
+		server.throttleConflicts(conflicts)
+		close(done)
+	}()
+	sleeper.waitDelay(t, ConflictDelay, ConflictDelay)
+	sleeper.resumeDelay()
+	<-done
+}
+
+func TestServerServiceTypeEnumeration(t *testing.T) {
+	server := NewServer()
+	services := []Service{newTestService(t)}
+	for _, name := range []string{"Test Scanner._http._tcp", "Test Printer._ipp._tcp"} {
+		service, err := NewService(
+			WithServiceName(name),
+			WithServiceHost("printer.local"),
+			WithServicePort(631),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		services = append(services, service)
+	}
+	for _, service := range services {
+		if err := server.RegisterService(service); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// RFC 6763: 9. Service Type Enumeration
+	serviceTypes := func() []string {
+		res, err := server.MessageReceived(newTestQuery(ServiceTypeEnumerationName+".local", dns.PTR))
+		if err != nil {
+			t.Fatal(err)
+		}
+		types := []string{}
+		if res == nil {
+			return types
+		}
+		for _, ptr := range res.Answers().LookupPTRRecordSet() {
+			types = append(types, ptr.DomainName())
+		}
+		slices.Sort(types)
+		return types
+	}
+
+	expected := []string{"_http._tcp.local", "_ipp._tcp.local"}
+	if types := serviceTypes(); !slices.Equal(types, expected) {
+		t.Errorf("%v != %v", types, expected)
+	}
+
+	// The service type is enumerated while any service of the type is registered.
+	if err := server.UnregisterService(services[0]); err != nil {
+		t.Fatal(err)
+	}
+	if types := serviceTypes(); !slices.Equal(types, expected) {
+		t.Errorf("%v != %v", types, expected)
+	}
+	if err := server.UnregisterService(services[2]); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"_http._tcp.local"}
+	if types := serviceTypes(); !slices.Equal(types, expected) {
+		t.Errorf("%v != %v", types, expected)
+	}
+}
+
+func TestServerSubtype(t *testing.T) {
+	for _, subtype := range []string{"_printer._color", "_printer._subway._tcp"} {
+		if _, err := NewService(WithServiceSubtypes(subtype)); err == nil {
+			t.Errorf("invalid subtype (%s) should be rejected", subtype)
+		}
+	}
+	subService, err := NewService(WithServiceSubtypes("_printer._sub._subway._tcp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subtypes := subService.Subtypes(); !slices.Equal(subtypes, []string{"_printer"}) {
+		t.Errorf("%v != %v", subtypes, []string{"_printer"})
+	}
+
+	server := NewServer()
+	service, err := NewService(
+		WithServiceName("Test Node._matterc._udp"),
+		WithServiceHost("node.local"),
+		WithServicePort(5540),
+		WithServiceSubtypes("_L840._sub._matterc._udp", "_S3", "_CM"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterService(service); err != nil {
+		t.Fatal(err)
+	}
+
+	// RFC 6763: 7.1. Selective Instance Enumeration (Subtypes)
+	for _, subtype := range []string{"_L840", "_S3", "_CM"} {
+		res, err := server.MessageReceived(newTestQuery(dns.NewNameWithStrings(subtype, Subtype, "_matterc._udp.local"), dns.PTR))
+		if err != nil || res == nil {
+			t.Fatalf("no response: %v", err)
+		}
+		ptrs := res.Answers().LookupPTRRecordSet()
+		if len(ptrs) != 1 || ptrs[0].DomainName() != "Test Node._matterc._udp.local" {
+			t.Errorf("invalid subtype answer: %v", res)
+		}
+		if len(res.Additions().LookupSRVRecordSet()) != 1 {
+			t.Errorf("SRV record not found in additions")
+		}
+	}
+
+	res, err := server.MessageReceived(newTestQuery("_T1._sub._matterc._udp.local", dns.PTR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != nil {
+		t.Errorf("unexpected response:\n%s", res.String())
+	}
+}
+
+func TestServerDuplicateAnswerSuppression(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterService(newTestService(t)); err != nil {
+		t.Fatal(err)
+	}
+	to, err := dns.NewAddrFromString("224.0.0.251:5353")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := dns.NewRequestMessage(
+		dns.WithMessageQuestions(newTestQuery("_http._tcp.local", dns.PTR).Questions()...),
+		dns.WithMessageTo(to),
+	)
+
+	sleeper := newTestSleeper(server)
+
+	// RFC 6762: 7.4. Duplicate Answer Suppression
+	// The queued answer is suppressed only if another host answers with the TTL not less than the TTL of this host.
+	for _, ttl := range []uint{DefaultRecordTTL / 2, DefaultRecordTTL} {
+		ptr := dns.NewPTRRecord().SetDomainName("Test Printer._http._tcp.local")
+		ptr.SetName("_http._tcp.local")
+		ptr.SetTTL(ttl)
+		otherResponse := dns.NewResponseMessage(
+			dns.WithMessageAnswers(ptr),
+			dns.WithMessageTo(to),
+		)
+
+		resCh := make(chan Message)
+		go func() {
+			res, _ := server.MessageReceived(query)
+			resCh <- res
+		}()
+		sleeper.waitDelay(t, MinResponseDelay, MaxResponseDelay)
+		if _, err := server.MessageReceived(otherResponse); err != nil {
+			t.Fatal(err)
+		}
+		sleeper.resumeDelay()
+		res := <-resCh
+		if isSuppressed := res == nil; isSuppressed != (DefaultRecordTTL <= ttl) {
+			t.Errorf("answer suppression with TTL %d: %t", ttl, isSuppressed)
+		}
+		// The multicast rate limit is reset for the next query.
+		server.multicastLimiter = newMulticastLimiter()
+	}
+}
+
+func TestServerUnicastResponseFallback(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterService(newTestService(t)); err != nil {
+		t.Fatal(err)
+	}
+	from, err := dns.NewAddrFromString("192.0.2.100:5353", dns.WithAddrTransport(dns.TransportMulticast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := dns.NewAddrFromString("192.0.2.2:5353")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := dns.NewRequestMessage(
+		dns.WithMessageQuestions(dns.NewQuestion(
+			dns.WithQuestionName("Test Printer._http._tcp.local"),
+			dns.WithQuestionType(dns.SRV),
+			dns.WithQuestionClass(QU|dns.IN),
+		)),
+		dns.WithMessageFrom(from),
+		dns.WithMessageTo(to),
+	)
+
+	// RFC 6762: 5.4. Questions Requesting Unicast Responses
+	// The record which has not been multicast within one quarter of its TTL is multicast even if the question requests a unicast response.
+	res, err := server.MessageReceived(query)
+	if err != nil || res == nil {
+		t.Fatalf("no response: %v", err)
+	}
+	if res.To() != nil {
+		t.Errorf("response is not multicast: %v", res.To())
+	}
+	res, err = server.MessageReceived(query)
+	if err != nil || res == nil {
+		t.Fatalf("no response: %v", err)
+	}
+	if res.To() == nil || !res.To().IP().Equal(from.IP()) {
+		t.Errorf("response is not unicast: %v", res.To())
+	}
+}
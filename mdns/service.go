@@ -45,6 +45,8 @@ type Service interface {
 	Port() int
 	// Addresses returns the service addresses.
 	Addresses() []net.IP
+	// Subtypes returns the service subtypes, such as "_printer".
+	Subtypes() []string
 	// ResourceRecordSet returns the service resource records.
 	ResourceRecordSet() ResourceRecordSet
 	// ResourceAttributes returns the service TXT attributes.
@@ -0,0 +1,48 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+// ServiceEventType represents a type of the service event.
+type ServiceEventType int
+
+const (
+	// ServiceAdded represents that a new service is found.
+	ServiceAdded ServiceEventType = iota
+	// ServiceUpdated represents that the SRV or TXT data of a found service is changed.
+	ServiceUpdated
+	// ServiceRemoved represents that a found service is removed by goodbye packets or TTL expiry.
+	ServiceRemoved
+)
+
+// String returns the string representation.
+func (t ServiceEventType) String() string {
+	switch t {
+	case ServiceAdded:
+		return "added"
+	case ServiceUpdated:
+		return "updated"
+	case ServiceRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// ServiceEvent represents a service event notified by browsing.
+type ServiceEvent interface {
+	// Type returns the event type.
+	Type() ServiceEventType
+	// Service returns the service of the event.
+	Service() Service
+}
@@ -0,0 +1,39 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+// serviceEventImpl represents a service event.
+type serviceEventImpl struct {
+	typ     ServiceEventType
+	service Service
+}
+
+// newServiceEvent returns a new service event with the specified type and service.
+func newServiceEvent(typ ServiceEventType, service Service) ServiceEvent {
+	return &serviceEventImpl{
+		typ:     typ,
+		service: service,
+	}
+}
+
+// Type returns the event type.
+func (event *serviceEventImpl) Type() ServiceEventType {
+	return event.typ
+}
+
+// Service returns the service of the event.
+func (event *serviceEventImpl) Service() Service {
+	return event.service
+}
@@ -26,12 +26,13 @@ import (
 // serviceImpl represents a SRV record.
 type serviceImpl struct {
 	Message
-	name   string
-	domain string
-	host   string
-	addrs  []net.IP
-	port   int
-	attrs  dns.Attributes
+	name     string
+	domain   string
+	host     string
+	addrs    []net.IP
+	subtypes []string
+	port     int
+	attrs    dns.Attributes
 }
 
 // ServiceOptions represents a service option.
@@ -69,6 +70,37 @@ func WithServicePort(port int) ServiceOptions {
 	}
 }
 
+// WithServiceAddresses returns a service option with the specified addresses.
+func WithServiceAddresses(addrs ...net.IP) ServiceOptions {
+	return func(srv *serviceImpl) error {
+		srv.addrs = append(srv.addrs, addrs...)
+		return nil
+	}
+}
+
+// WithServiceSubtypes returns a service option with the specified subtypes, such as "_printer" or "_printer._sub._http._tcp".
+// RFC 6763: 7.1. Selective Instance Enumeration (Subtypes).
+func WithServiceSubtypes(subtypes ...string) ServiceOptions {
+	return func(srv *serviceImpl) error {
+		for _, subtype := range subtypes {
+			subtype, _, _ = strings.Cut(subtype, dns.LabelSeparator+Subtype+dns.LabelSeparator)
+			if len(subtype) == 0 || strings.Contains(subtype, dns.LabelSeparator) {
+				return fmt.Errorf("%w subtype: %s", ErrInvalid, subtype)
+			}
+			srv.subtypes = append(srv.subtypes, subtype)
+		}
+		return nil
+	}
+}
+
+// WithServiceAttribute returns a service option with the specified TXT attribute.
+func WithServiceAttribute(name string, value string) ServiceOptions {
+	return func(srv *serviceImpl) error {
+		srv.attrs = append(srv.attrs, dns.NewAttributeWithNameValue(name, value))
+		return nil
+	}
+}
+
 // WithServiceMessage returns a service option with the specified message.
 func WithServiceMessage(msg Message) ServiceOptions {
 	return func(srv *serviceImpl) error {
@@ -83,13 +115,14 @@ func NewService(opts ...ServiceOptions) (Service, error) {
 
 func newService(opts ...ServiceOptions) (*serviceImpl, error) {
 	srv := &serviceImpl{
-		Message: nil,
-		name:    "",
-		domain:  "",
-		host:    "",
-		addrs:   []net.IP{},
-		port:    0,
-		attrs:   dns.Attributes{},
+		Message:  nil,
+		name:     "",
+		domain:   "",
+		host:     "",
+		addrs:    []net.IP{},
+		subtypes: []string{},
+		port:     0,
+		attrs:    dns.Attributes{},
 	}
 	for _, opt := range opts {
 		err := opt(srv)
@@ -125,6 +158,11 @@ func (srv *serviceImpl) Addresses() []net.IP {
 	return srv.addrs
 }
 
+// Subtypes returns the service subtypes.
+func (srv *serviceImpl) Subtypes() []string {
+	return srv.subtypes
+}
+
 // parseMessage updates the service data by the specified message.
 func (srv *serviceImpl) parseMessage(msg Message) error {
 	srv.Message = msg
@@ -139,6 +177,9 @@ func (srv *serviceImpl) parseMessage(msg Message) error {
 
 // ResourceRecordSet returns the service resource records.
 func (srv *serviceImpl) ResourceRecordSet() ResourceRecordSet {
+	if srv.Message == nil {
+		return nil
+	}
 	return srv.Message.ResourceRecordSet()
 }
 
@@ -154,21 +195,33 @@ func (srv *serviceImpl) LookupResourceAttribute(name string) (Attribute, bool) {
 
 // LookupResourceByName returns the resource record of the specified name.
 func (srv *serviceImpl) LookupResourceByName(name string) (ResourceRecord, bool) {
+	if srv.Message == nil {
+		return nil, false
+	}
 	return srv.Message.LookupResourceRecordByName(name)
 }
 
 // LookupResourceByNameRegex returns the resource record of the specified name regex.
 func (srv *serviceImpl) LookupResourceByNameRegex(re *regexp.Regexp) (ResourceRecord, bool) {
+	if srv.Message == nil {
+		return nil, false
+	}
 	return srv.Message.LookupResourceRecordByNameRegex(re)
 }
 
 // LookupResourceByNamePrefix returns the resource record of the specified name prefix.
 func (srv *serviceImpl) LookupResourceByNamePrefix(prefix string) (ResourceRecord, bool) {
+	if srv.Message == nil {
+		return nil, false
+	}
 	return srv.Message.LookupResourceRecordByNamePrefix(prefix)
 }
 
 // LookupResourceByNameSuffix returns the resource record of the specified name suffix.
 func (srv *serviceImpl) LookupResourceByNameSuffix(suffix string) (ResourceRecord, bool) {
+	if srv.Message == nil {
+		return nil, false
+	}
 	return srv.Message.LookupResourceRecordByNameSuffix(suffix)
 }
 
@@ -284,7 +337,8 @@ func (srv *serviceImpl) Equal(other Service) bool {
 
 // String returns the string representation.
 func (srv *serviceImpl) String() string {
-	str := dns.NewNameWithStrings(srv.name, srv.host, srv.domain)
+	host := strings.TrimSuffix(srv.host, dns.LabelSeparator+srv.domain)
+	str := dns.NewNameWithStrings(srv.name, host, srv.domain)
 	if len(str) == 0 {
 		for _, record := range srv.ResourceRecordSet() {
 			name := record.Name()
@@ -299,7 +353,7 @@ func (srv *serviceImpl) String() string {
 	for _, addr := range srv.Addresses() {
 		addrs = append(addrs, addr.String())
 	}
-	if len(addrs) == 0 {
+	if len(addrs) == 0 && srv.Message != nil {
 		from := srv.From()
 		if from != nil {
 			addrs = append(addrs, from.IP().String())
@@ -14,6 +14,10 @@
 
 package mdns
 
+import (
+	"slices"
+)
+
 // serviceSet represents a service array.
 type serviceSet struct {
 	services []Service
@@ -60,6 +64,17 @@ func (services *serviceSet) AddServices(newServiceSet []Service) int {
 	return addedCount
 }
 
+// RemoveService removes the specified service from the service array.
+func (services *serviceSet) RemoveService(targetService Service) bool {
+	for n, service := range services.services {
+		if service.Equal(targetService) {
+			services.services = append(slices.Clone(services.services[:n]), services.services[n+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
 // Clear removes all services from the service array.
 func (services *serviceSet) Clear() {
 	services.services = []Service{}
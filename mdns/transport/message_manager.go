@@ -46,6 +46,16 @@ func (mgr *MessageManager) AnnounceMessage(msg dns.Message) error {
 	return mgr.UnicastManager.AnnounceMessage(msg)
 }
 
+// AnnounceInterfaceMessage sends the message returned by the specified function for the local address of each interface to the multicast address.
+func (mgr *MessageManager) AnnounceInterfaceMessage(newMessage func(ifaddr string) dns.Message) error {
+	return mgr.UnicastManager.AnnounceInterfaceMessage(newMessage)
+}
+
+// IsRunning returns true whether the local servers are running, otherwise false.
+func (mgr *MessageManager) IsRunning() bool {
+	return mgr.MulticastManager.IsRunning() || mgr.UnicastManager.IsRunning()
+}
+
 // Start starts this server.
 func (mgr *MessageManager) Start() error {
 	starter := []func() error{
@@ -92,7 +92,6 @@ func (mgr *MulticastManager) Start() error {
 			if err != nil {
 				continue
 			}
-			server.SetMessageProcessor(mgr.processor)
 			mgr.Servers = append(mgr.Servers, server)
 		}
 	}
@@ -74,6 +74,15 @@ func handleMulticastRequestMessage(server *MulticastServer, reqMsg dns.Message)
 	if err != nil || resMsg == nil {
 		return
 	}
+	// RFC 6762: 5.4. Questions Requesting Unicast Responses
+	// RFC 6762: 6.7. Legacy Unicast Responses
+	// The response which has the destination address is sent by unicast, otherwise by multicast.
+	if resMsg.To() != nil {
+		if err := server.responseForRequest(reqMsg, resMsg); err != nil {
+			log.Error(err)
+		}
+		return
+	}
 	server.AnnounceMessage(resMsg)
 }
 
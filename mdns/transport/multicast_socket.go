@@ -56,9 +56,13 @@ func (sock *MulticastSocket) Bind(ifi *net.Interface, ifaddr string) error {
 		return fmt.Errorf("%w (%s)", err, ifi.Name)
 	}
 
-	sock.Conn.SetReadBuffer(sock.GetReadBufferSize())
+	conn := sock.conn()
+	if conn == nil {
+		return errSocketClosed
+	}
+	conn.SetReadBuffer(sock.GetReadBufferSize())
 
-	rawConn, err := sock.Conn.SyscallConn()
+	rawConn, err := conn.SyscallConn()
 	if err != nil {
 		return err
 	}
@@ -19,6 +19,7 @@ import (
 	"io"
 	"net"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/cybergarage/go-logger/log"
@@ -28,6 +29,7 @@ import (
 // A UDPSocket represents a socket for UDP.
 type UDPSocket struct {
 	*Socket
+	connMutex      sync.RWMutex
 	Conn           *net.UDPConn
 	ReadBufferSize int
 	ReadBuffer     []byte
@@ -38,6 +40,7 @@ type UDPSocket struct {
 func NewUDPSocket(transport dns.Transport) *UDPSocket {
 	sock := &UDPSocket{
 		Socket:         NewSocket(),
+		connMutex:      sync.RWMutex{},
 		Conn:           nil,
 		ReadBufferSize: MaxPacketSize,
 		ReadBuffer:     make([]byte, 0),
@@ -58,8 +61,25 @@ func (sock *UDPSocket) GetReadBufferSize() int {
 	return sock.ReadBufferSize
 }
 
+// setConn sets the specified connection as the current opened socket.
+func (sock *UDPSocket) setConn(conn *net.UDPConn) {
+	sock.connMutex.Lock()
+	defer sock.connMutex.Unlock()
+	sock.Conn = conn
+}
+
+// conn returns the current opened socket, or nil if the socket is closed.
+func (sock *UDPSocket) conn() *net.UDPConn {
+	sock.connMutex.RLock()
+	defer sock.connMutex.RUnlock()
+	return sock.Conn
+}
+
 // Close closes the current opened socket.
+// Close waits until the running SendMessage calls finish, so that no message is sent after Close returns.
 func (sock *UDPSocket) Close() error {
+	sock.connMutex.Lock()
+	defer sock.connMutex.Unlock()
 	conn := sock.Conn
 	if conn == nil {
 		return nil
@@ -72,6 +92,13 @@ func (sock *UDPSocket) Close() error {
 
 // SendMessage sends the message to the destination address.
 func (sock *UDPSocket) SendMessage(toAddr string, toPort int, msg dns.Message) (int, error) {
+	sock.connMutex.RLock()
+	defer sock.connMutex.RUnlock()
+	conn := sock.Conn
+	if conn == nil {
+		return 0, errSocketClosed
+	}
+
 	toUDPAddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(toAddr, strconv.Itoa(toPort)))
 	if err != nil {
 		return 0, err
@@ -88,16 +115,32 @@ func (sock *UDPSocket) SendMessage(toAddr string, toPort int, msg dns.Message) (
 	)
 	log.HexDebug(msgBytes)
 
-	return sock.Conn.WriteToUDP(msgBytes, toUDPAddr)
+	return conn.WriteToUDP(msgBytes, toUDPAddr)
+}
+
+// responseForRequest sends a specified response message to the destination address of the response, or the request node if the destination is not specified.
+func (sock *UDPSocket) responseForRequest(reqMsg dns.Message, resMsg dns.Message) error {
+	to := resMsg.To()
+	if to == nil {
+		to = reqMsg.From()
+	}
+	dstAddr := to.IP().String()
+	if 0 < len(to.Zone()) && to.IP().To4() == nil {
+		dstAddr += "%" + to.Zone()
+	}
+	_, err := sock.SendMessage(dstAddr, to.Port(), resMsg)
+	return err
 }
 
 // ReadMessage reads a message from the current opened socket.
 func (sock *UDPSocket) ReadMessage() (dns.Message, error) {
-	if sock.Conn == nil {
+	// The connection is not locked while reading because Close unblocks the read by closing the connection.
+	conn := sock.conn()
+	if conn == nil {
 		return nil, fmt.Errorf("%w: %w", io.EOF, errSocketClosed)
 	}
 
-	n, fromAddr, err := sock.Conn.ReadFromUDP(sock.ReadBuffer)
+	n, fromAddr, err := conn.ReadFromUDP(sock.ReadBuffer)
 	if err != nil {
 		return nil, err
 	}
@@ -123,9 +166,20 @@ func (sock *UDPSocket) ReadMessage() (dns.Message, error) {
 		return nil, err
 	}
 
+	opts := []dns.MessageOption{
+		dns.WithMessageFrom(add),
+	}
+	to, err := dns.NewAddrFromString(
+		net.JoinHostPort(toAddr, strconv.Itoa(toPort)),
+		dns.WithAddrTransport(sock.Transport),
+	)
+	if err == nil {
+		opts = append(opts, dns.WithMessageTo(to))
+	}
+
 	msg, err := dns.NewMessageWithBytes(
 		msgBytes,
-		dns.WithMessageFrom(add),
+		opts...,
 	)
 	if err != nil {
 		log.Debugf("Failed to parse DNS message: %s", err)
@@ -170,20 +170,57 @@ func (mgr *UnicastManager) SendMessage(addr string, port int, msg dns.Message) (
 	return 0, errUnicastServerNotRunning
 }
 
-// AnnounceMessage sends a message to the multicast address.
+// AnnounceMessage sends a message to the multicast address on every bound interface.
+// The message is sent only once for each interface and address family, and an error is returned only if it could not be sent on any interface.
 func (mgr *UnicastManager) AnnounceMessage(msg dns.Message) error {
+	return mgr.AnnounceInterfaceMessage(func(ifaddr string) dns.Message {
+		return msg
+	})
+}
+
+// AnnounceInterfaceMessage sends the message returned by the specified function for the local address of each bound interface
+// to the multicast address. No message is sent on the interface if the function returns nil.
+// The message is sent only once for each interface and address family, and an error is returned only if it could not be sent on any interface.
+func (mgr *UnicastManager) AnnounceInterfaceMessage(newMessage func(ifaddr string) dns.Message) error {
+	if len(mgr.Servers) == 0 {
+		return errUnicastServerNotRunning
+	}
+
+	announced := false
+	announcedInterfaces := map[string]bool{}
 	var lastErr error
 	for _, server := range mgr.Servers {
+		ifname := ""
+		if ifi, err := server.UDPSocket.ListenInterface(); err == nil {
+			ifname = ifi.Name
+		}
+		ifaddr, _ := server.UDPSocket.ListenAddr()
+		toAddr := MulticastIPv4Address
+		if IsIPv6Address(ifaddr) {
+			toAddr = MulticastIPv6Address
+		}
+		key := ifname + "/" + toAddr
+		if announcedInterfaces[key] {
+			continue
+		}
+		msg := newMessage(ifaddr)
+		if msg == nil {
+			announcedInterfaces[key] = true
+			announced = true
+			continue
+		}
 		err := server.AnnounceMessage(msg)
-		if err == nil {
-			return nil
+		if err != nil {
+			lastErr = err
+			continue
 		}
-		lastErr = err
+		announcedInterfaces[key] = true
+		announced = true
 	}
-	if lastErr != nil {
-		return lastErr
+	if announced {
+		return nil
 	}
-	return errUnicastServerNotRunning
+	return lastErr
 }
 
 // PostMessage posts a message to the destination address and gets the response message.
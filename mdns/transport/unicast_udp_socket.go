@@ -87,7 +87,7 @@ func (sock *UnicastUDPSocket) Bind(ifi *net.Interface, ifaddr string, port int)
 		return fmt.Errorf("invalid udp packet connection: %T", pc)
 	}
 
-	sock.Conn = conn
+	sock.setConn(conn)
 
 	sock.SetListenStatus(ifi, ifaddr, port)
 
@@ -107,11 +107,3 @@ func (sock *UnicastUDPSocket) AnnounceMessage(msg dns.Message) error {
 	_, err = sock.SendMessage(toAddr, Port, msg)
 	return err
 }
-
-// responseForRequest sends a specified response message to the request node.
-func (sock *UnicastUDPSocket) responseForRequest(reqMsg dns.Message, resMsg dns.Message) error {
-	dstAddr := reqMsg.From().IP().String()
-	dstPort := reqMsg.From().Port()
-	_, err := sock.SendMessage(dstAddr, dstPort, resMsg)
-	return err
-}
@@ -0,0 +1,113 @@
+// Copyright (C) 2022 The go-mdns Authors All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mdns
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/cybergarage/go-mdns/mdns/dns"
+)
+
+// RFC 6762 - Multicast DNS.
+const (
+	// MinTruncatedQueryDelay is the minimum delay to wait for the following known-answer packets of a truncated query.
+	// 7.2. Multipacket Known-Answer Suppression
+	MinTruncatedQueryDelay = 400 * time.Millisecond
+	// MaxTruncatedQueryDelay is the maximum delay to wait for the following known-answer packets of a truncated query.
+	// 7.2. Multipacket Known-Answer Suppression
+	MaxTruncatedQueryDelay = 500 * time.Millisecond
+)
+
+// truncatedQuery represents a pending query which has the multipacket known-answer list.
+type truncatedQuery struct {
+	query        Message
+	questions    []dns.Question
+	knownAnswers ResourceRecordSet
+}
+
+// truncatedQuerySet represents a set of the pending truncated queries for each querier.
+type truncatedQuerySet struct {
+	sync.Mutex
+	queries map[string]*truncatedQuery
+}
+
+// newTruncatedQuerySet returns a new truncated query set.
+func newTruncatedQuerySet() *truncatedQuerySet {
+	return &truncatedQuerySet{
+		Mutex:   sync.Mutex{},
+		queries: map[string]*truncatedQuery{},
+	}
+}
+
+// appendTruncatedQuery appends the questions and known answers of the specified query into the pending query of the specified querier.
+// A new pending query is created only when the specified query has the TC bit. It returns true as created when a new pending query is created,
+// and the caller should wait for the following packets and flush it, and returns true as appended when the query is appended to the existing pending query.
+func (set *truncatedQuerySet) appendTruncatedQuery(key string, query Message) (bool, bool) {
+	set.Lock()
+	defer set.Unlock()
+	pendingQuery, ok := set.queries[key]
+	if !ok {
+		if !query.TC() {
+			return false, false
+		}
+		set.queries[key] = &truncatedQuery{
+			query:        query,
+			questions:    query.Questions(),
+			knownAnswers: query.Answers(),
+		}
+		return true, false
+	}
+	pendingQuery.questions = append(pendingQuery.questions, query.Questions()...)
+	pendingQuery.knownAnswers = append(pendingQuery.knownAnswers, query.Answers()...)
+	return false, true
+}
+
+// flushTruncatedQuery removes the pending query of the specified querier, and returns a query message merging all the received packets.
+func (set *truncatedQuerySet) flushTruncatedQuery(key string) Message {
+	set.Lock()
+	defer set.Unlock()
+	pendingQuery := set.queries[key]
+	delete(set.queries, key)
+	return dns.NewRequestMessage(
+		dns.WithMessageID(pendingQuery.query.ID()),
+		dns.WithMessageQuestions(pendingQuery.questions...),
+		dns.WithMessageAnswers(pendingQuery.knownAnswers...),
+		dns.WithMessageFrom(pendingQuery.query.From()),
+		dns.WithMessageTo(pendingQuery.query.To()),
+	)
+}
+
+// receiveTruncatedQuery returns the query message merging the multipacket known-answer list if the specified query is truncated,
+// or nil if the specified query is a following packet of the other truncated query.
+// RFC 6762: 7.2. Multipacket Known-Answer Suppression
+func (server *Server) receiveTruncatedQuery(query Message) Message {
+	if query.From() == nil {
+		return query
+	}
+	key := query.From().String()
+	created, appended := server.appendTruncatedQuery(key, query)
+	switch {
+	case appended:
+		return nil
+	case !created:
+		return query
+	}
+	// If the TC bit is set, the responder SHOULD wait for the additional known-answer packets
+	// for a random amount of time selected with uniform random distribution in the range 400-500 ms.
+	server.sleep(MinTruncatedQueryDelay + rand.N(MaxTruncatedQueryDelay-MinTruncatedQueryDelay))
+	return server.flushTruncatedQuery(key)
+}
@@ -16,7 +16,12 @@ package mdnstest
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/cybergarage/go-logger/log"
 	"github.com/cybergarage/go-mdns/mdns"
@@ -50,3 +55,250 @@ func TestClient(t *testing.T) {
 		return
 	}
 }
+
+func TestClientBrowse(t *testing.T) {
+	newService := func(value string) mdns.Service {
+		return newTestService(t, "go-mdns-browse", mdns.WithServiceAttribute("version", value))
+	}
+
+	client := newTestClient(t)
+
+	server := newTestServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := client.Browse(ctx, mdns.NewQuery(mdns.WithQueryService("_http._tcp")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitEvent := func(typ mdns.ServiceEventType, value string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case event := <-events:
+				if event.Service().Name() != "go-mdns-browse._http._tcp" || event.Type() != typ {
+					continue
+				}
+				if attr, ok := event.Service().LookupResourceAttribute("version"); typ != mdns.ServiceRemoved && (!ok || attr.Value() != value) {
+					continue
+				}
+				return
+			case <-timeout:
+				t.Fatalf("%s event not received", typ)
+			}
+		}
+	}
+
+	if err := server.RegisterService(newService("1")); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(mdns.ServiceAdded, "1")
+
+	service := newService("2")
+	if err := server.UpdateService(service); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(mdns.ServiceUpdated, "2")
+
+	// RFC 6762: 10.1. Goodbye Packets
+	if err := server.UnregisterService(service); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(mdns.ServiceRemoved, "")
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestClientResolve(t *testing.T) {
+	service := newTestService(t, "go-mdns-resolve", mdns.WithServiceAttribute("path", "/"))
+
+	client := newTestClient(t)
+
+	server := newTestServer(t)
+	if err := server.RegisterService(service); err != nil {
+		t.Fatal(err)
+	}
+	client.Cache().Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	resolved, err := client.Resolve(ctx, "go-mdns-resolve._http._tcp.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Port() != 8080 || len(resolved.Addresses()) == 0 {
+		t.Errorf("invalid service: %s", resolved.String())
+	}
+	if _, ok := resolved.LookupResourceAttribute("path"); !ok {
+		t.Errorf("TXT attribute (path) not found")
+	}
+
+	if _, err := client.Resolve(ctx, "go-mdns-unknown._http._tcp.local"); !errors.Is(err, mdns.ErrNotFound) {
+		t.Errorf("unknown service is resolved: %v", err)
+	}
+}
+
+func TestClientLookup(t *testing.T) {
+	client := newTestClient(t)
+
+	server := mdns.NewServer()
+	server.SetHost("go-mdns-lookup")
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	timeout := 5 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// The lookups return as soon as the unique answers are received.
+	start := time.Now()
+	addrs, err := client.LookupHost(ctx, server.Host())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); timeout <= elapsed {
+		t.Errorf("lookup waits until the timeout: %s", elapsed)
+	}
+
+	// RFC 6762: 4. Reverse Address Mapping
+	hosts, err := client.LookupAddr(ctx, addrs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(hosts, server.Host()) {
+		t.Errorf("host (%s) not found in %v", server.Host(), hosts)
+	}
+}
+
+func TestClientConcurrentQueries(t *testing.T) {
+	serviceTypes := []string{"_go-mdns-a._tcp", "_go-mdns-b._tcp", "_go-mdns-c._tcp"}
+
+	server := newTestServer(t)
+	for _, serviceType := range serviceTypes {
+		service := newTestService(t, "go-mdns-concurrent", mdns.WithServiceName("go-mdns-concurrent."+serviceType))
+		if err := server.RegisterService(service); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := newTestClient(t)
+
+	// The concurrent queries run in parallel, and each query collects its own answers.
+	timeout := 2 * time.Second
+	results := make([][]mdns.Service, len(serviceTypes))
+	handled := make([]atomic.Bool, len(serviceTypes))
+	var wg sync.WaitGroup
+	start := time.Now()
+	for n, serviceType := range serviceTypes {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			query := mdns.NewQuery(
+				mdns.WithQueryService(serviceType),
+				mdns.WithQueryMessageHandler(func(msg mdns.Message) {
+					handled[n].Store(true)
+				}),
+			)
+			services, err := client.Query(ctx, query)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results[n] = services
+		})
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); 2*timeout <= elapsed {
+		t.Errorf("queries are not run in parallel: %s", elapsed)
+	}
+	for n, serviceType := range serviceTypes {
+		if !handled[n].Load() {
+			t.Errorf("message handler of %s is not called", serviceType)
+		}
+		if len(results[n]) != 1 || results[n][0].Name() != "go-mdns-concurrent."+serviceType {
+			t.Errorf("invalid services of %s: %v", serviceType, results[n])
+		}
+	}
+}
+
+func TestClientQueryTermination(t *testing.T) {
+	service := newTestService(t, "go-mdns-termination", mdns.WithServiceAttribute("version", "1"))
+
+	server := newTestServer(t)
+	if err := server.RegisterService(service); err != nil {
+		t.Fatal(err)
+	}
+
+	client := newTestClient(t)
+
+	timeout := 5 * time.Second
+	tests := []struct {
+		name      string
+		opts      []mdns.QueryOption
+		nServices int
+	}{
+		{"MaxResults", []mdns.QueryOption{mdns.WithQueryMaxResults(1)}, 1},
+		{"IdleTimeout", []mdns.QueryOption{mdns.WithQueryIdleTimeout(500 * time.Millisecond)}, 1},
+		{
+			"Filter",
+			[]mdns.QueryOption{
+				mdns.WithQueryFilter(mdns.NewAttributeFilter("version", "2")),
+				mdns.WithQueryIdleTimeout(500 * time.Millisecond),
+			},
+			0,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			opts := append([]mdns.QueryOption{mdns.WithQueryService("_http._tcp")}, test.opts...)
+			start := time.Now()
+			services, err := client.Query(ctx, mdns.NewQuery(opts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); timeout <= elapsed {
+				t.Errorf("query waits until the timeout: %s", elapsed)
+			}
+			services = slices.DeleteFunc(services, func(service mdns.Service) bool {
+				return service.Name() != "go-mdns-termination._http._tcp"
+			})
+			if len(services) != test.nServices {
+				t.Errorf("services %d != %d", len(services), test.nServices)
+			}
+		})
+	}
+
+	t.Run("Stream", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		query := mdns.NewQuery(
+			mdns.WithQueryService("_http._tcp"),
+			mdns.WithQueryFilter(mdns.NewAttributeFilter("version")),
+			mdns.WithQueryIdleTimeout(500*time.Millisecond),
+		)
+		nServices := 0
+		start := time.Now()
+		services, err := client.QueryStream(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for service := range services {
+			if service.Name() == "go-mdns-termination._http._tcp" {
+				nServices++
+			}
+		}
+		if elapsed := time.Since(start); timeout <= elapsed {
+			t.Errorf("query stream waits until the timeout: %s", elapsed)
+		}
+		if nServices != 1 {
+			t.Errorf("services %d != %d", nServices, 1)
+		}
+	})
+}
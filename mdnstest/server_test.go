@@ -15,11 +15,56 @@
 package mdnstest
 
 import (
+	"context"
+	"net"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/cybergarage/go-mdns/mdns"
+	"github.com/cybergarage/go-mdns/mdns/dns"
 )
 
+// newTestService returns a new test service which has the specified instance name on the specified host name.
+// The default service type, port and address can be overridden by the specified options.
+func newTestService(t *testing.T, name string, opts ...mdns.ServiceOptions) mdns.Service {
+	t.Helper()
+	opts = append([]mdns.ServiceOptions{
+		mdns.WithServiceName(name + "._http._tcp"),
+		mdns.WithServiceDomain(mdns.LocalDomain),
+		mdns.WithServiceHost(name + ".local"),
+		mdns.WithServicePort(8080),
+		mdns.WithServiceAddresses(net.IPv4(192, 0, 2, 1)),
+	}, opts...)
+	service, err := mdns.NewService(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+// newTestServer returns a new running server which is stopped when the test finishes.
+func newTestServer(t *testing.T) *mdns.Server {
+	t.Helper()
+	server := mdns.NewServer()
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Stop() })
+	return server
+}
+
+// newTestClient returns a new running client which is stopped when the test finishes.
+func newTestClient(t *testing.T) mdns.Client {
+	t.Helper()
+	client := mdns.NewClient()
+	if err := client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Stop() })
+	return client
+}
+
 func TestServer(t *testing.T) {
 	server := mdns.NewServer()
 	if err := server.Start(); err != nil {
@@ -29,3 +74,251 @@ func TestServer(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestServerService(t *testing.T) {
+	service := newTestService(t, "go-mdns-test", mdns.WithServiceAttribute("path", "/"))
+
+	server := mdns.NewServer()
+	if err := server.RegisterService(service); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	client := newTestClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	query := mdns.NewQuery(
+		mdns.WithQueryService("_http._tcp"),
+		mdns.WithQueryDomain(mdns.LocalDomain),
+	)
+	services, err := client.Query(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, found := range services {
+		if found.Name() == service.Name() && found.Port() == service.Port() {
+			return
+		}
+	}
+	t.Errorf("service (%s) not found: %v", service.Name(), services)
+}
+
+func TestServerConflict(t *testing.T) {
+	server := newTestServer(t)
+	if err := server.RegisterService(newTestService(t, "go-mdns-probe")); err != nil {
+		t.Fatal(err)
+	}
+
+	other := mdns.NewServer()
+	stateCh := make(chan mdns.RegistrationState, 16)
+	other.SetRegistrationHandler(func(service mdns.Service, state mdns.RegistrationState) {
+		stateCh <- state
+	})
+	if err := other.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer other.Stop()
+	if err := other.RegisterService(newTestService(t, "go-mdns-probe", mdns.WithServicePort(8081))); err != nil {
+		t.Fatal(err)
+	}
+
+	states := []mdns.RegistrationState{}
+	timeout := time.After(10 * time.Second)
+	for len(states) == 0 || states[len(states)-1] != mdns.RegistrationRegistered {
+		select {
+		case state := <-stateCh:
+			states = append(states, state)
+		case <-timeout:
+			t.Fatalf("service is not registered: %v", states)
+		}
+	}
+
+	services := other.Services()
+	if len(services) != 1 {
+		t.Fatalf("service is not registered: %v", services)
+	}
+	if services[0].Name() == "go-mdns-probe._http._tcp" {
+		t.Errorf("conflicting service is not renamed: %s", services[0].Name())
+	}
+	if !slices.Contains(states, mdns.RegistrationRenamed) {
+		t.Errorf("invalid registration states: %v", states)
+	}
+}
+
+func TestServerAnnouncement(t *testing.T) {
+	newService := func(version string) mdns.Service {
+		return newTestService(t, "go-mdns-announce", mdns.WithServiceAttribute("version", version))
+	}
+
+	// The announcement is received on every interface and address family,
+	// so the announcements received within a half of AnnounceInterval are counted as one round.
+	type announcement struct {
+		version string
+		time    time.Time
+	}
+	announcementCh := make(chan announcement, 256)
+	client := mdns.NewClient()
+	client.RegisterMessageHandler(func(msg mdns.Message) {
+		if !msg.IsResponse() {
+			return
+		}
+		service, err := mdns.NewService(mdns.WithServiceMessage(msg))
+		if err != nil || service.Name() != "go-mdns-announce._http._tcp" {
+			return
+		}
+		txts := msg.ResourceRecordSet().LookupTXTRecordSet()
+		if len(txts) == 0 || txts[0].TTL() == 0 {
+			return
+		}
+		attr, ok := service.LookupResourceAttribute("version")
+		if !ok {
+			return
+		}
+		select {
+		case announcementCh <- announcement{version: attr.Value(), time: time.Now()}:
+		default:
+		}
+	})
+	if err := client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	server := newTestServer(t)
+
+	waitAnnouncementRounds := func(version string) {
+		t.Helper()
+		rounds := []time.Time{}
+		timeout := time.After(mdns.AnnounceCount * 3 * mdns.AnnounceInterval)
+		for len(rounds) < mdns.AnnounceCount {
+			select {
+			case a := <-announcementCh:
+				if a.version != version {
+					continue
+				}
+				if 0 < len(rounds) && a.time.Sub(rounds[len(rounds)-1]) < mdns.AnnounceInterval/2 {
+					continue
+				}
+				rounds = append(rounds, a.time)
+			case <-timeout:
+				t.Fatalf("announcement rounds %d < %d", len(rounds), mdns.AnnounceCount)
+			}
+		}
+	}
+
+	// RFC 6762: 8.3. Announcing
+	if err := server.RegisterService(newService("1")); err != nil {
+		t.Fatal(err)
+	}
+	waitAnnouncementRounds("1")
+
+	// RFC 6762: 8.4. Updating
+	if err := server.UpdateService(newService("2")); err != nil {
+		t.Fatal(err)
+	}
+	waitAnnouncementRounds("2")
+}
+
+func TestServerGoodbye(t *testing.T) {
+	service := newTestService(t, "go-mdns-goodbye")
+
+	client := mdns.NewClient()
+	goodbyeCh := make(chan mdns.ResourceRecord, 64)
+	client.RegisterMessageHandler(func(msg mdns.Message) {
+		if !msg.IsResponse() {
+			return
+		}
+		for _, record := range msg.Answers() {
+			if record.TTL() == 0 && record.IsName("_http._tcp.local") {
+				goodbyeCh <- record
+			}
+		}
+	})
+	if err := client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	server := newTestServer(t)
+
+	waitGoodbye := func() {
+		t.Helper()
+		select {
+		case record := <-goodbyeCh:
+			if record.Content() != "go-mdns-goodbye._http._tcp.local" {
+				t.Errorf("invalid goodbye record: %s", record.Content())
+			}
+		case <-time.After(time.Second):
+			t.Errorf("goodbye packet not received")
+		}
+	}
+
+	// RFC 6762: 10.1. Goodbye Packets
+	if err := server.RegisterService(service); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.UnregisterService(service); err != nil {
+		t.Fatal(err)
+	}
+	waitGoodbye()
+
+	if err := server.RegisterService(service); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	waitGoodbye()
+}
+
+func TestServerLegacyUnicast(t *testing.T) {
+	service := newTestService(t, "go-mdns-legacy")
+
+	server := newTestServer(t)
+	if err := server.RegisterService(service); err != nil {
+		t.Fatal(err)
+	}
+
+	// RFC 6762: 6.7. Legacy Unicast Responses
+	// Send a query from an ephemeral port like a simple resolver.
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	query := dns.NewRequestMessage(
+		dns.WithMessageID(0xBEEF),
+		dns.WithMessageQuestions(dns.NewQuestion(
+			dns.WithQuestionName("go-mdns-legacy.local"),
+			dns.WithQuestionType(dns.A),
+			dns.WithQuestionClass(dns.IN),
+		)),
+	)
+	if _, err := conn.WriteToUDP(query.Bytes(), &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 9000)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := dns.NewMessageWithBytes(buf[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.IsResponse() || res.ID() != 0xBEEF || len(res.Questions()) != 1 {
+		t.Errorf("invalid legacy response: id=%X, questions=%d", res.ID(), len(res.Questions()))
+	}
+	if len(res.Answers()) != 1 || mdns.LegacyUnicastTTL < res.Answers()[0].TTL() {
+		t.Errorf("invalid legacy answers:\n%s", res.String())
+	}
+}